@@ -5,14 +5,27 @@ import (
 	"fmt"
 )
 
+// ErrStaleCheckpoint can be returned by a step, possibly wrapped, when state
+// committed by an earlier checkpoint is no longer valid, such as an expired
+// token. The flow discards its checkpoint and the next attempt starts again
+// from the first step.
+var ErrStaleCheckpoint = errors.New("stale checkpoint")
+
 // AttemptError wraps an error with attempt and step information.
 type AttemptError struct {
-	Attempt int
-	Step    int
-	Err     error
+	Attempt  int
+	Step     int    // 1-based, or 0 when the WithPreflightCheck check failed
+	StepName string // set with Step.Name, empty for unnamed steps
+	Err      error
 }
 
 func (e *AttemptError) Error() string {
+	if e.Step == 0 {
+		return fmt.Sprintf("attempt %d, preflight: %v", e.Attempt, e.Err)
+	}
+	if e.StepName != "" {
+		return fmt.Sprintf("attempt %d, step '%s': %v", e.Attempt, e.StepName, e.Err)
+	}
 	return fmt.Sprintf("attempt %d, step %d: %v", e.Attempt, e.Step, e.Err)
 }
 
@@ -20,6 +33,66 @@ func (e *AttemptError) Unwrap() error {
 	return e.Err
 }
 
+// LoopDetectedError is returned when a flow exceeds the WithMaxIterations
+// ceiling, which happens when checkpoints keep resetting the normal limits.
+type LoopDetectedError struct {
+	Iterations int
+	Err        error // last attempt error
+}
+
+func (e *LoopDetectedError) Error() string {
+	return fmt.Sprintf("loop detected after %d iterations: %v", e.Iterations, e.Err)
+}
+
+func (e *LoopDetectedError) Unwrap() error {
+	return e.Err
+}
+
+// StepExhaustedError is returned when a step with MaxAttempts has run and
+// failed that many times.
+type StepExhaustedError struct {
+	Step     int // 1-based
+	Attempts int
+	Err      error // last attempt error
+}
+
+func (e *StepExhaustedError) Error() string {
+	return fmt.Sprintf("step %d exhausted after %d attempts: %v", e.Step, e.Attempts, e.Err)
+}
+
+func (e *StepExhaustedError) Unwrap() error {
+	return e.Err
+}
+
+// StuckError is returned when the WithMaxSameOutput step keeps producing the
+// same output.
+type StuckError struct {
+	Step    int // 1-based
+	Repeats int // times in a row the output repeated
+	Output  any
+}
+
+func (e *StuckError) Error() string {
+	return fmt.Sprintf("step %d stuck: same output %v repeated %d times", e.Step, e.Output, e.Repeats)
+}
+
+// RepeatedError is passed to the WithOnRetry hook under
+// WithCollapseRepeatedRetries, standing for Count consecutive failures with
+// the same class and message. Err is the last of them.
+type RepeatedError struct {
+	Class ErrorClass
+	Count int
+	Err   error
+}
+
+func (e *RepeatedError) Error() string {
+	return fmt.Sprintf("%s x%d: %v", e.Class, e.Count, e.Err)
+}
+
+func (e *RepeatedError) Unwrap() error {
+	return e.Err
+}
+
 func fullUnwrap(err error) error {
 	for {
 		u := errors.Unwrap(err)
@@ -29,3 +102,24 @@ func fullUnwrap(err error) error {
 		err = u
 	}
 }
+
+// leafErrors unwraps err down to its root causes, following both
+// Unwrap() error and the Unwrap() []error of joined errors.
+func leafErrors(err error) []error {
+	if multi, ok := err.(interface{ Unwrap() []error }); ok {
+		var leaves []error
+		for _, e := range multi.Unwrap() {
+			if e != nil {
+				leaves = append(leaves, leafErrors(e)...)
+			}
+		}
+		if len(leaves) > 0 {
+			return leaves
+		}
+		return []error{err}
+	}
+	if u := errors.Unwrap(err); u != nil {
+		return leafErrors(u)
+	}
+	return []error{err}
+}
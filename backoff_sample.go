@@ -1,29 +1,127 @@
 package retryflow
 
-import "time"
+import (
+	"math"
+	"time"
+)
 
 // Backoff strategies
+//
+// The loop passes WithInitialBackoff as prev on the first attempt, so the
+// strategies below return prev unchanged there and the first sleep is the
+// configured initial backoff.
+
+// ExponentialBackoff doubles the delay on every retry after the first.
 func ExponentialBackoff(attempt int, prev time.Duration) time.Duration {
-	if prev == 0 {
-		return 500 * time.Millisecond
+	if attempt <= 1 {
+		return prev
 	}
 	return prev * 2
 }
 
+// NewExponentialBackoff returns a strategy multiplying the delay by
+// multiplier on every retry, e.g. 1.5. The first interval is
+// WithInitialBackoff, which the loop passes as prev on the first attempt.
+func NewExponentialBackoff(multiplier float64) func(attempt int, prev time.Duration) time.Duration {
+	return func(attempt int, prev time.Duration) time.Duration {
+		if attempt <= 1 {
+			return prev
+		}
+		return time.Duration(math.Round(float64(prev) * multiplier))
+	}
+}
+
+// ConstantBackoff repeats the previous delay, which stays at
+// WithInitialBackoff only as long as nothing else changes it, such as
+// WithMaxBackoff clamping or WithResetBackoffOnClassChange. Prefer
+// NewConstantBackoff, which does not depend on prev.
 func ConstantBackoff(attempt int, prev time.Duration) time.Duration {
-	if prev == 0 {
-		return 500 * time.Millisecond
+	return prev
+}
+
+// NewConstantBackoff returns a strategy computing d for every retry,
+// whatever prev is. Jitter and WithMaxBackoff still apply to the sleep.
+func NewConstantBackoff(d time.Duration) func(attempt int, prev time.Duration) time.Duration {
+	return func(int, time.Duration) time.Duration {
+		return d
 	}
-	return prev // Constant uses initial, but since prev is initial after first, it stays constant
 }
 
-func FibonacciBackoff(attempt int, _ time.Duration) time.Duration {
+// FibonacciBackoff grows the delay along the Fibonacci sequence scaled by
+// the initial backoff, initial*fib(attempt). Like LinearBackoff it derives
+// each step from prev, as prev*fib(attempt)/fib(attempt-1). Prefer
+// NewFibonacciBackoff, which does not depend on prev.
+func FibonacciBackoff(attempt int, prev time.Duration) time.Duration {
 	if attempt <= 1 {
-		return 500 * time.Millisecond
+		return prev
+	}
+	n := min(attempt, len(fibs)-1) // the ratio has converged long before
+	return time.Duration(math.Round(float64(prev) * float64(fibs[n]) / float64(fibs[n-1])))
+}
+
+// NewFibonacciBackoff returns a strategy computing base*fib(attempt), that is
+// base, base, 2*base, 3*base, 5*base, 8*base..., whatever prev is. Delays
+// too long for a time.Duration saturate, and WithMaxBackoff caps the sleep.
+func NewFibonacciBackoff(base time.Duration) func(attempt int, prev time.Duration) time.Duration {
+	return func(attempt int, _ time.Duration) time.Duration {
+		n := max(attempt, 1)
+		if n >= len(fibs) || base > 0 && fibs[n] > math.MaxInt64/int64(base) {
+			return math.MaxInt64
+		}
+		return base * time.Duration(fibs[n])
+	}
+}
+
+// fibs holds the Fibonacci numbers fib(0) to fib(92), the last one to fit in
+// an int64, so that the strategies look them up instead of iterating.
+var fibs = func() []int64 {
+	f := make([]int64, 93)
+	f[1] = 1
+	for i := 2; i < len(f); i++ {
+		f[i] = f[i-1] + f[i-2]
+	}
+	return f
+}()
+
+// decorrelatedJitter draws the next "decorrelated jitter" delay,
+// min(cap, random_between(base, prev*3)), with int63n.
+func decorrelatedJitter(base, cap, prev time.Duration, int63n func(int64) int64) time.Duration {
+	prev = max(prev, base)
+	upper := prev * 3
+	if upper <= base {
+		return min(base, cap)
 	}
-	a, b := int64(1), int64(1)
-	for i := 3; i <= attempt; i++ {
-		a, b = b, a+b
+	return min(cap, base+time.Duration(int63n(int64(upper-base))))
+}
+
+// LinearBackoff grows the delay linearly, initial*attempt. The loop passes
+// WithInitialBackoff as prev on the first attempt and the previous delay
+// afterwards, so the strategy derives each step from prev as
+// prev*attempt/(attempt-1) and needs no captured state.
+func LinearBackoff(attempt int, prev time.Duration) time.Duration {
+	return NewPolynomialBackoff(1)(attempt, prev)
+}
+
+// NewPolynomialBackoff returns a strategy growing the delay as
+// initial*attempt^exponent, derived from prev like LinearBackoff.
+// Once WithMaxBackoff clamps a delay, later delays stay at the cap.
+func NewPolynomialBackoff(exponent float64) func(attempt int, prev time.Duration) time.Duration {
+	return func(attempt int, prev time.Duration) time.Duration {
+		if attempt <= 1 {
+			return prev
+		}
+		ratio := math.Pow(float64(attempt)/float64(attempt-1), exponent)
+		return time.Duration(math.Round(float64(prev) * ratio))
+	}
+}
+
+// loadScaled lengthens the backoff d by (1 + load*k) for the load reported
+// by the WithLoadSource source, clamped to [0, 1], without exceeding
+// maxBackoff.
+func (o *options) loadScaled(d time.Duration) time.Duration {
+	if o.loadSource == nil {
+		return d
 	}
-	return time.Duration(b) * 500 * time.Millisecond
+	load := min(max(o.loadSource(), 0), 1)
+	return min(time.Duration(float64(d)*(1+load*o.loadFactor)), o.maxBackoff)
 }
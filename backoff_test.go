@@ -0,0 +1,665 @@
+package retryflow_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/Vealcoo/retryflow"
+	"github.com/Vealcoo/retryflow/retryflowtest"
+)
+
+func TestDecorrelatedJitterBounds(t *testing.T) {
+	base, cap := 10*time.Millisecond, time.Second
+	clock := retryflowtest.NewClock(time.Now())
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return errors.New("fail") }),
+	),
+		retryflow.WithClock(clock),
+		retryflow.WithMaxRetries(1000),
+		retryflow.WithMaxElapsedTime(0),
+		retryflow.WithInitialBackoff(base),
+		retryflow.WithMaxBackoff(cap),
+		retryflow.WithJitter(0),
+		retryflow.WithDecorrelatedJitter(base, cap),
+	)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	prev := base
+	for i, d := range clock.Sleeps() {
+		if d < base || d > cap || d > 3*prev {
+			t.Fatalf("sleep %d: %v outside [%v, min(%v, %v)]", i+1, d, base, cap, 3*prev)
+		}
+		prev = d
+	}
+}
+
+func TestDecorrelatedJitterHonorsMaxBackoff(t *testing.T) {
+	clock := retryflowtest.NewClock(time.Now())
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return errors.New("fail") }),
+	),
+		retryflow.WithClock(clock),
+		retryflow.WithMaxRetries(50),
+		retryflow.WithInitialBackoff(10*time.Millisecond),
+		retryflow.WithMaxBackoff(50*time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithDecorrelatedJitter(10*time.Millisecond, time.Second),
+	)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	for i, d := range clock.Sleeps() {
+		if d < 10*time.Millisecond || d > 50*time.Millisecond {
+			t.Errorf("sleep %d: %v outside [10ms, 50ms]", i+1, d)
+		}
+	}
+}
+
+func TestBackoffFuncElapsed(t *testing.T) {
+	clock := retryflowtest.NewClock(time.Now())
+	var elapsedSeen []time.Duration
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return errors.New("fail") }),
+	),
+		retryflow.WithClock(clock),
+		retryflow.WithMaxRetries(6),
+		retryflow.WithInitialBackoff(10*time.Millisecond),
+		retryflow.WithMaxBackoff(time.Minute),
+		retryflow.WithJitter(0),
+		retryflow.WithBackoffFunc(func(attempt int, elapsed, prev time.Duration) time.Duration {
+			elapsedSeen = append(elapsedSeen, elapsed)
+			if elapsed >= 30*time.Millisecond {
+				return 10 * time.Second
+			}
+			return 10 * time.Millisecond
+		}),
+	)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	wantSleeps := []time.Duration{10 * time.Millisecond, 10 * time.Millisecond, 10 * time.Millisecond, 10 * time.Second, 10 * time.Second}
+	sleeps := clock.Sleeps()
+	if len(sleeps) != len(wantSleeps) {
+		t.Fatalf("expected sleeps %v, got %v", wantSleeps, sleeps)
+	}
+	for i := range wantSleeps {
+		if sleeps[i] != wantSleeps[i] {
+			t.Errorf("sleep %d: expected %v, got %v", i+1, wantSleeps[i], sleeps[i])
+		}
+	}
+	if elapsedSeen[0] != 0 || elapsedSeen[3] != 30*time.Millisecond {
+		t.Errorf("unexpected elapsed times passed to strategy: %v", elapsedSeen)
+	}
+}
+
+func TestJitterModes(t *testing.T) {
+	const backoff = 100 * time.Millisecond
+	tests := []struct {
+		mode           retryflow.JitterMode
+		lo, hi         time.Duration // inclusive bounds of each sleep
+		meanLo, meanHi time.Duration
+	}{
+		{retryflow.JitterNone, backoff, backoff, backoff, backoff},
+		{retryflow.JitterFull, 0, backoff, 35 * time.Millisecond, 65 * time.Millisecond},
+		{retryflow.JitterEqual, backoff / 2, backoff, 65 * time.Millisecond, 85 * time.Millisecond},
+		{retryflow.JitterAdditive, 80 * time.Millisecond, 120 * time.Millisecond, 90 * time.Millisecond, 110 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode.String(), func(t *testing.T) {
+			clock := retryflowtest.NewClock(time.Now())
+			_ = retryflow.Retry(context.Background(), retryflow.Seq(
+				retryflow.Exec(func(ctx context.Context) error { return errors.New("fail") }),
+			),
+				retryflow.WithClock(clock),
+				retryflow.WithMaxRetries(500),
+				retryflow.WithMaxElapsedTime(0),
+				retryflow.WithInitialBackoff(backoff),
+				retryflow.WithMaxBackoff(backoff),
+				retryflow.WithBackoffStrategy(retryflow.ConstantBackoff),
+				retryflow.WithJitter(20*time.Millisecond),
+				retryflow.WithJitterMode(tt.mode),
+			)
+
+			sleeps := clock.Sleeps()
+			if len(sleeps) != 499 {
+				t.Fatalf("expected 499 sleeps, got %d", len(sleeps))
+			}
+			var sum time.Duration
+			for _, d := range sleeps {
+				if d < tt.lo || d > tt.hi {
+					t.Fatalf("sleep %v outside [%v, %v]", d, tt.lo, tt.hi)
+				}
+				sum += d
+			}
+			if mean := sum / time.Duration(len(sleeps)); mean < tt.meanLo || mean > tt.meanHi {
+				t.Errorf("mean sleep %v outside [%v, %v]", mean, tt.meanLo, tt.meanHi)
+			}
+		})
+	}
+}
+
+func TestMinBackoffByClass(t *testing.T) {
+	clock := retryflowtest.NewClock(time.Now())
+	_ = retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return errors.New("429") }),
+	),
+		retryflow.WithClock(clock),
+		retryflow.WithMaxRetries(200),
+		retryflow.WithMaxElapsedTime(0),
+		retryflow.WithInitialBackoff(time.Second),
+		retryflow.WithMaxBackoff(time.Second),
+		retryflow.WithBackoffStrategy(retryflow.ConstantBackoff),
+		retryflow.WithJitter(500*time.Millisecond),
+		retryflow.WithErrorClassifier(func(error) retryflow.ErrorClass { return retryflow.ClassRateLimit }),
+		retryflow.WithMinBackoffByClass(map[retryflow.ErrorClass]time.Duration{retryflow.ClassRateLimit: time.Second}),
+	)
+
+	sleeps := clock.Sleeps()
+	if len(sleeps) != 199 {
+		t.Fatalf("expected 199 sleeps, got %d", len(sleeps))
+	}
+	for i, d := range sleeps {
+		if d < time.Second {
+			t.Fatalf("sleep %d: %v below the 1s ratelimit floor", i+1, d)
+		}
+	}
+}
+
+func TestStepJitter(t *testing.T) {
+	sleepsOf := func(step *retryflow.Step) []time.Duration {
+		clock := retryflowtest.NewClock(time.Now())
+		_ = retryflow.Retry(context.Background(), retryflow.Seq(step),
+			retryflow.WithClock(clock),
+			retryflow.WithMaxRetries(100),
+			retryflow.WithInitialBackoff(100*time.Millisecond),
+			retryflow.WithMaxBackoff(100*time.Millisecond),
+			retryflow.WithBackoffStrategy(retryflow.ConstantBackoff),
+			retryflow.WithJitter(0),
+		)
+		return clock.Sleeps()
+	}
+	fail := func(ctx context.Context) error { return errors.New("fail") }
+
+	for _, d := range sleepsOf(retryflow.Exec(fail)) {
+		if d != 100*time.Millisecond {
+			t.Fatalf("expected unjittered 100ms sleeps without a step jitter, got %v", d)
+		}
+	}
+
+	distinct := make(map[time.Duration]bool)
+	for _, d := range sleepsOf(retryflow.Exec(fail).Jitter(50 * time.Millisecond)) {
+		if d < 50*time.Millisecond || d > 150*time.Millisecond {
+			t.Fatalf("sleep %v outside the step jitter of ±50ms", d)
+		}
+		distinct[d] = true
+	}
+	if len(distinct) < 10 {
+		t.Errorf("expected varied sleeps with a step jitter, got %d distinct values", len(distinct))
+	}
+}
+
+func TestLinearAndPolynomialBackoff(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name     string
+		strategy func(int, time.Duration) time.Duration
+		max      time.Duration
+		want     []time.Duration
+	}{
+		{"linear", retryflow.LinearBackoff, time.Second, []time.Duration{10 * ms, 20 * ms, 30 * ms, 40 * ms, 50 * ms}},
+		{"linear capped", retryflow.LinearBackoff, 35 * ms, []time.Duration{10 * ms, 20 * ms, 30 * ms, 35 * ms, 35 * ms}},
+		{"quadratic", retryflow.NewPolynomialBackoff(2), time.Second, []time.Duration{10 * ms, 40 * ms, 90 * ms, 160 * ms, 250 * ms}},
+		{"quadratic capped", retryflow.NewPolynomialBackoff(2), 100 * ms, []time.Duration{10 * ms, 40 * ms, 90 * ms, 100 * ms, 100 * ms}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := retryflowtest.NewClock(time.Now())
+			_ = retryflow.Retry(context.Background(), retryflow.Seq(
+				retryflow.Exec(func(ctx context.Context) error { return errors.New("fail") }),
+			),
+				retryflow.WithClock(clock),
+				retryflow.WithMaxRetries(len(tt.want)+1),
+				retryflow.WithInitialBackoff(10*ms),
+				retryflow.WithMaxBackoff(tt.max),
+				retryflow.WithJitter(0),
+				retryflow.WithBackoffStrategy(tt.strategy),
+			)
+			if got := clock.Sleeps(); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("expected sleeps %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestResetBackoffOnClassChange(t *testing.T) {
+	classes := []retryflow.ErrorClass{
+		retryflow.ClassRateLimit,
+		retryflow.ClassRateLimit,
+		retryflow.ClassRateLimit,
+		retryflow.ClassTransient,
+		retryflow.ClassTransient,
+	}
+	attempts := 0
+	clock := retryflowtest.NewClock(time.Now())
+	_ = retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			attempts++
+			return errors.New("fail")
+		}),
+	),
+		retryflow.WithClock(clock),
+		retryflow.WithMaxRetries(len(classes)),
+		retryflow.WithInitialBackoff(10*time.Millisecond),
+		retryflow.WithMaxBackoff(time.Second),
+		retryflow.WithJitter(0),
+		retryflow.WithErrorClassifier(func(error) retryflow.ErrorClass { return classes[attempts-1] }),
+		retryflow.WithResetBackoffOnClassChange(true),
+	)
+
+	ms := time.Millisecond
+	want := []time.Duration{10 * ms, 20 * ms, 40 * ms, 10 * ms}
+	if got := clock.Sleeps(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected sleeps %v, got %v", want, got)
+	}
+}
+
+func TestLoadSource(t *testing.T) {
+	sleepsWithLoad := func(load float64) []time.Duration {
+		clock := retryflowtest.NewClock(time.Now())
+		_ = retryflow.Retry(context.Background(), retryflow.Seq(
+			retryflow.Exec(func(ctx context.Context) error { return errors.New("fail") }),
+		),
+			retryflow.WithClock(clock),
+			retryflow.WithMaxRetries(4),
+			retryflow.WithInitialBackoff(10*time.Millisecond),
+			retryflow.WithMaxBackoff(time.Second),
+			retryflow.WithJitter(0),
+			retryflow.WithLoadSource(func() float64 { return load }, 3),
+		)
+		return clock.Sleeps()
+	}
+
+	idle, busy := sleepsWithLoad(0), sleepsWithLoad(1)
+	if len(idle) != 3 || len(busy) != 3 {
+		t.Fatalf("expected 3 sleeps each, got %v and %v", idle, busy)
+	}
+	for i := range idle {
+		if busy[i] != 4*idle[i] {
+			t.Errorf("sleep %d: expected %v under full load, got %v", i+1, 4*idle[i], busy[i])
+		}
+	}
+}
+
+func TestSimulateScheduleMatchesRetry(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []retryflow.Option
+	}{
+		{"Exponential", []retryflow.Option{
+			retryflow.WithMaxRetries(8),
+			retryflow.WithInitialBackoff(10 * time.Millisecond),
+			retryflow.WithMaxBackoff(500 * time.Millisecond),
+			retryflow.WithJitter(0),
+		}},
+		{"ElapsedBudget", []retryflow.Option{
+			retryflow.WithMaxRetries(-1),
+			retryflow.WithMaxElapsedTime(time.Second),
+			retryflow.WithInitialBackoff(50 * time.Millisecond),
+			retryflow.WithJitterMode(retryflow.JitterNone),
+			retryflow.WithBackoffFunc(func(attempt int, elapsed, prev time.Duration) time.Duration {
+				return prev + elapsed/2
+			}),
+		}},
+		{"TypeNameClassFloor", []retryflow.Option{
+			retryflow.WithMaxRetries(5),
+			retryflow.WithInitialBackoff(10 * time.Millisecond),
+			retryflow.WithJitter(0),
+			retryflow.WithMinBackoffByClass(map[retryflow.ErrorClass]time.Duration{
+				retryflow.NewErrorClass(errors.New("")): 35 * time.Millisecond,
+			}),
+		}},
+		{"ClassifierFloor", []retryflow.Option{
+			retryflow.WithMaxRetries(5),
+			retryflow.WithInitialBackoff(10 * time.Millisecond),
+			retryflow.WithJitter(0),
+			retryflow.WithErrorClassifier(func(error) retryflow.ErrorClass { return retryflow.ClassRateLimit }),
+			retryflow.WithMinBackoffByClass(map[retryflow.ErrorClass]time.Duration{retryflow.ClassRateLimit: 25 * time.Millisecond}),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := retryflowtest.NewClock(time.Now())
+			_ = retryflow.Retry(context.Background(), retryflow.Seq(
+				retryflow.Exec(func(ctx context.Context) error { return errors.New("fail") }),
+			), append(tt.opts, retryflow.WithClock(clock))...)
+
+			got := retryflow.SimulateSchedule(tt.opts...)
+			if len(got) < 3 {
+				t.Fatalf("expected several sleeps, got %v", got)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(clock.Sleeps()) {
+				t.Errorf("expected simulated schedule %v to match observed sleeps %v", got, clock.Sleeps())
+			}
+		})
+	}
+}
+
+func TestSimulateScheduleZeroSleeps(t *testing.T) {
+	done := make(chan []time.Duration, 1)
+	go func() {
+		done <- retryflow.SimulateSchedule(
+			retryflow.WithMaxRetries(-1),
+			retryflow.WithMaxElapsedTime(time.Second),
+			retryflow.WithBackoffStrategy(retryflow.NewConstantBackoff(0)),
+			retryflow.WithJitter(0),
+		)
+	}()
+	select {
+	case sleeps := <-done:
+		if len(sleeps) != 10000 {
+			t.Errorf("expected the schedule to stop at 10000 retries, got %d", len(sleeps))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected SimulateSchedule to return when no sleep adds up to maxElapsedTime")
+	}
+}
+
+func TestSimulateScheduleJitterIsDeterministic(t *testing.T) {
+	opts := []retryflow.Option{
+		retryflow.WithMaxRetries(-1),
+		retryflow.WithMaxElapsedTime(0),
+		retryflow.WithInitialBackoff(100 * time.Millisecond),
+		retryflow.WithMaxBackoff(time.Second),
+		retryflow.WithJitterMode(retryflow.JitterFull),
+	}
+	first := retryflow.SimulateSchedule(opts...)
+	if len(first) != 20 {
+		t.Fatalf("expected the schedule of an unbounded flow to be capped at 20 sleeps, got %d", len(first))
+	}
+	if second := retryflow.SimulateSchedule(opts...); fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Errorf("expected identical schedules, got %v and %v", first, second)
+	}
+	for i, d := range first {
+		if d < 0 || d > time.Second {
+			t.Errorf("sleep %d: %v outside [0, 1s]", i+1, d)
+		}
+	}
+}
+
+func TestAdaptiveJitter(t *testing.T) {
+	const runs = 300
+	base := time.Second
+	windows := []time.Duration{10, 20, 30, 40, 10, 20} // ms, per sleep
+	maxDeviation := make([]time.Duration, len(windows))
+
+	for range runs {
+		clock := retryflowtest.NewClock(time.Now())
+		failures := 0
+		err := retryflow.Retry(context.Background(), retryflow.Seq(
+			// Fails 4 times, then step 2 fails twice after step 1's success
+			retryflow.Exec(func(ctx context.Context) error {
+				if failures < 4 {
+					failures++
+					return errors.New("throttled")
+				}
+				return nil
+			}),
+			retryflow.Exec(func(ctx context.Context) error {
+				if failures < 6 {
+					failures++
+					return errors.New("throttled")
+				}
+				return nil
+			}),
+		),
+			retryflow.WithClock(clock),
+			retryflow.WithAdaptiveJitter(true),
+			retryflow.WithErrorClassifier(func(error) retryflow.ErrorClass { return retryflow.ClassRateLimit }),
+			retryflow.WithBackoffStrategy(func(attempt int, prev time.Duration) time.Duration { return base }),
+			retryflow.WithInitialBackoff(base),
+			retryflow.WithMaxBackoff(base),
+			retryflow.WithJitter(10*time.Millisecond),
+			retryflow.WithMaxRetries(10),
+			retryflow.WithMaxElapsedTime(0),
+		)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		sleeps := clock.Sleeps()
+		if len(sleeps) != len(windows) {
+			t.Fatalf("expected %d sleeps, got %v", len(windows), sleeps)
+		}
+		for i, d := range sleeps {
+			maxDeviation[i] = max(maxDeviation[i], (d - base).Abs())
+		}
+	}
+
+	for i, w := range windows {
+		window := w * time.Millisecond
+		if maxDeviation[i] > window {
+			t.Errorf("sleep %d: deviation %v outside the ±%v window", i+1, maxDeviation[i], window)
+		}
+		// Over many runs each window is nearly filled, so growth is visible
+		if maxDeviation[i] < window*3/4 {
+			t.Errorf("sleep %d: deviation %v, expected the ±%v window to be used", i+1, maxDeviation[i], window)
+		}
+	}
+}
+
+func TestWithRandReproducible(t *testing.T) {
+	run := func() []time.Duration {
+		clock := retryflowtest.NewClock(time.Now())
+		_ = retryflow.Retry(context.Background(), retryflow.Seq(
+			retryflow.Exec(func(ctx context.Context) error { return errors.New("fail") }),
+		),
+			retryflow.WithClock(clock),
+			retryflow.WithRand(rand.New(rand.NewSource(42))),
+			retryflow.WithMaxRetries(8),
+			retryflow.WithSimpleExponential(100*time.Millisecond, 10*time.Second, 0.5),
+		)
+		return clock.Sleeps()
+	}
+
+	first, second := run(), run()
+	if len(first) != 7 {
+		t.Fatalf("expected 7 sleeps, got %v", first)
+	}
+	if fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Errorf("expected identical sleeps with the same seed, got %v and %v", first, second)
+	}
+	if first[0]%(100*time.Millisecond) == 0 {
+		t.Errorf("expected jittered sleeps, got %v", first)
+	}
+}
+
+func TestNewConstantBackoff(t *testing.T) {
+	strategy := retryflow.NewConstantBackoff(300 * time.Millisecond)
+	for attempt, prev := range []time.Duration{0, time.Millisecond, 300 * time.Millisecond, 7 * time.Second, time.Hour} {
+		if d := strategy(attempt+1, prev); d != 300*time.Millisecond {
+			t.Errorf("attempt %d with prev %v: expected 300ms, got %v", attempt+1, prev, d)
+		}
+	}
+
+	// The computed intervals stay constant whatever the jitter did to the sleeps
+	var computed []time.Duration
+	clock := retryflowtest.NewClock(time.Now())
+	_ = retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return errors.New("fail") }),
+	),
+		retryflow.WithClock(clock),
+		retryflow.WithMaxRetries(6),
+		retryflow.WithInitialBackoff(100*time.Millisecond),
+		retryflow.WithJitter(50*time.Millisecond),
+		retryflow.WithBackoffFunc(func(attempt int, elapsed, prev time.Duration) time.Duration {
+			d := strategy(attempt, prev)
+			computed = append(computed, d)
+			return d
+		}),
+	)
+	if len(computed) != 5 {
+		t.Fatalf("expected 5 computed intervals, got %v", computed)
+	}
+	for i, d := range computed {
+		if d != 300*time.Millisecond {
+			t.Errorf("interval %d: expected 300ms, got %v", i+1, d)
+		}
+	}
+	for i, d := range clock.Sleeps() {
+		if d < 250*time.Millisecond || d > 350*time.Millisecond {
+			t.Errorf("sleep %d: %v outside [250ms, 350ms]", i+1, d)
+		}
+	}
+}
+
+func TestNewExponentialBackoff(t *testing.T) {
+	clock := retryflowtest.NewClock(time.Now())
+	_ = retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return errors.New("fail") }),
+	),
+		retryflow.WithClock(clock),
+		retryflow.WithMaxRetries(6),
+		retryflow.WithInitialBackoff(40*time.Millisecond),
+		retryflow.WithMaxBackoff(150*time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithBackoffStrategy(retryflow.NewExponentialBackoff(1.5)),
+	)
+	want := []time.Duration{40 * time.Millisecond, 60 * time.Millisecond, 90 * time.Millisecond, 135 * time.Millisecond, 150 * time.Millisecond}
+	if got := clock.Sleeps(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected sleeps %v, got %v", want, got)
+	}
+}
+
+func TestInitialBackoffIsFirstSleep(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name     string
+		strategy func(attempt int, prev time.Duration) time.Duration
+		want     []time.Duration
+	}{
+		{"Exponential", retryflow.ExponentialBackoff, []time.Duration{10 * ms, 20 * ms, 40 * ms, 80 * ms}},
+		{"Constant", retryflow.ConstantBackoff, []time.Duration{10 * ms, 10 * ms, 10 * ms, 10 * ms}},
+		{"Fibonacci", retryflow.FibonacciBackoff, []time.Duration{10 * ms, 10 * ms, 20 * ms, 30 * ms}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := retryflowtest.NewClock(time.Now())
+			_ = retryflow.Retry(context.Background(), retryflow.Seq(
+				retryflow.Exec(func(ctx context.Context) error { return errors.New("fail") }),
+			),
+				retryflow.WithClock(clock),
+				retryflow.WithMaxRetries(len(tt.want)+1),
+				retryflow.WithInitialBackoff(10*ms),
+				retryflow.WithJitter(0),
+				retryflow.WithBackoffStrategy(tt.strategy),
+			)
+			if got := clock.Sleeps(); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("expected sleeps %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewFibonacciBackoff(t *testing.T) {
+	base := 10 * time.Millisecond
+	strategy := retryflow.NewFibonacciBackoff(base)
+	for i, f := range []time.Duration{1, 1, 2, 3, 5, 8, 13, 21} {
+		// prev is ignored
+		if d := strategy(i+1, time.Hour); d != f*base {
+			t.Errorf("attempt %d: expected %v, got %v", i+1, f*base, d)
+		}
+	}
+	if d := strategy(90, 0); d != time.Duration(math.MaxInt64) {
+		t.Errorf("expected an overflowing delay to saturate, got %v", d)
+	}
+	if d := strategy(1000, 0); d != time.Duration(math.MaxInt64) {
+		t.Errorf("expected a delay past the table to saturate, got %v", d)
+	}
+
+	// The main loop still caps the sleeps at maxBackoff
+	clock := retryflowtest.NewClock(time.Now())
+	_ = retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return errors.New("fail") }),
+	),
+		retryflow.WithClock(clock),
+		retryflow.WithMaxRetries(100),
+		retryflow.WithMaxElapsedTime(0),
+		retryflow.WithInitialBackoff(base),
+		retryflow.WithMaxBackoff(45*time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithBackoffStrategy(strategy),
+	)
+	sleeps := clock.Sleeps()
+	if len(sleeps) != 99 {
+		t.Fatalf("expected 99 sleeps, got %d", len(sleeps))
+	}
+	ms := time.Millisecond
+	if want := []time.Duration{10 * ms, 10 * ms, 20 * ms, 30 * ms, 45 * ms, 45 * ms}; fmt.Sprint(sleeps[:6]) != fmt.Sprint(want) {
+		t.Errorf("expected sleeps to start with %v, got %v", want, sleeps[:6])
+	}
+	if last := sleeps[len(sleeps)-1]; last != 45*ms {
+		t.Errorf("expected the last sleep capped at 45ms, got %v", last)
+	}
+}
+
+func BenchmarkFibonacciBackoff(b *testing.B) {
+	strategy := retryflow.NewFibonacciBackoff(10 * time.Millisecond)
+	for i := 0; b.Loop(); i++ {
+		strategy(i%100+1, 0)
+	}
+}
+
+func TestDecorrelatedJitterWithRand(t *testing.T) {
+	run := func(seed int64) []time.Duration {
+		clock := retryflowtest.NewClock(time.Now())
+		_ = retryflow.Retry(context.Background(), retryflow.Seq(
+			retryflow.Exec(func(ctx context.Context) error { return errors.New("fail") }),
+		),
+			retryflow.WithClock(clock),
+			retryflow.WithRand(rand.New(rand.NewSource(seed))),
+			retryflow.WithMaxRetries(8),
+			retryflow.WithInitialBackoff(10*time.Millisecond),
+			retryflow.WithMaxBackoff(time.Second),
+			retryflow.WithJitter(0),
+			retryflow.WithDecorrelatedJitter(10*time.Millisecond, time.Second),
+		)
+		return clock.Sleeps()
+	}
+
+	first, second := run(42), run(42)
+	if len(first) != 7 {
+		t.Fatalf("expected 7 sleeps, got %v", first)
+	}
+	if fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Errorf("expected identical sleeps with the same seed, got %v and %v", first, second)
+	}
+	if other := run(7); fmt.Sprint(first) == fmt.Sprint(other) {
+		t.Errorf("expected the seed to drive the sleeps, got %v for both", first)
+	}
+	prev := 10 * time.Millisecond
+	for i, d := range first {
+		if d < 10*time.Millisecond || d > 3*max(prev, 10*time.Millisecond) {
+			t.Errorf("sleep %d: %v outside [10ms, 3*%v]", i+1, d, prev)
+		}
+		prev = d
+	}
+
+	// The simulation draws from its fixed seed instead of the global source
+	opts := []retryflow.Option{
+		retryflow.WithMaxRetries(8),
+		retryflow.WithInitialBackoff(10 * time.Millisecond),
+		retryflow.WithMaxBackoff(time.Second),
+		retryflow.WithJitter(0),
+		retryflow.WithDecorrelatedJitter(10*time.Millisecond, time.Second),
+	}
+	if a, b := retryflow.SimulateSchedule(opts...), retryflow.SimulateSchedule(opts...); fmt.Sprint(a) != fmt.Sprint(b) {
+		t.Errorf("expected identical simulations, got %v and %v", a, b)
+	}
+}
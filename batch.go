@@ -0,0 +1,105 @@
+package retryflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// BatchError reports the sub-operations of a ForEach step that failed.
+// Skipped counts the items left unrun when WithErrorRateThreshold aborted
+// the batch.
+type BatchError struct {
+	Total   int
+	Failed  int
+	Skipped int
+	Errs    []error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%d of %d items failed: %v", e.Failed, e.Total, errors.Join(e.Errs...))
+}
+
+func (e *BatchError) Unwrap() []error {
+	return e.Errs
+}
+
+// FailureRate returns the fraction of the items run that failed.
+func (e *BatchError) FailureRate() float64 {
+	if e.Total-e.Skipped <= 0 {
+		return 0
+	}
+	return float64(e.Failed) / float64(e.Total-e.Skipped)
+}
+
+// ForEach creates a step that applies fn to every element of its []In input
+// and outputs the results as []Out. If any element fails, the step fails
+// with a *BatchError and the whole batch is retried. Under
+// WithErrorRateThreshold the batch stops as soon as the failure rate of the
+// items run so far is over the threshold.
+func ForEach[In any, Out any](fn func(context.Context, In) (Out, error)) *Step {
+	s := &Step{}
+	s.run = func(ctx context.Context, input any) (any, error) {
+		items, ok := input.([]In)
+		if !ok && input != nil {
+			return nil, fmt.Errorf("expected %T but got %T", *new([]In), input)
+		}
+		out := make([]Out, len(items))
+		var errs []error
+		info, _ := ctx.Value(attemptKey{}).(*attemptInfo)
+		for i, item := range items {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			v, err := fn(ctx, item)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("item %d: %w", i, err))
+				if info != nil && info.errorRateExceeded(len(errs), i+1) {
+					return nil, &BatchError{Total: len(items), Failed: len(errs), Skipped: len(items) - i - 1, Errs: errs}
+				}
+				continue
+			}
+			out[i] = v
+		}
+		if len(errs) > 0 {
+			return nil, &BatchError{Total: len(items), Failed: len(errs), Errs: errs}
+		}
+		return out, nil
+	}
+	return s
+}
+
+// RetryEach runs the flow built by build for every element of inputs, with
+// up to concurrency flows at a time, or all at once if concurrency is not
+// positive. Each flow is a Retry call with opts, so every flow shares them:
+// hooks are called from several flows at once, and options that write to a
+// caller's value, such as WithStats or WithResults, or that identify a flow,
+// such as WithFlowKey, WithIdempotencyKey or WithCheckpointStore, must not
+// be passed. The result holds the error of each input at its index, nil for
+// the inputs that succeeded. Once ctx is done, inputs that have not started
+// yet fail with ctx.Err() without running.
+func RetryEach[T any](ctx context.Context, inputs []T, build func(T) Steps, concurrency int, opts ...Option) []error {
+	errs := make([]error, len(inputs))
+	var g errgroup.Group
+	if concurrency > 0 {
+		g.SetLimit(concurrency)
+	}
+	for i, input := range inputs {
+		if ctx.Err() != nil {
+			errs[i] = ctx.Err()
+			continue
+		}
+		g.Go(func() error {
+			if ctx.Err() != nil {
+				errs[i] = ctx.Err()
+				return nil
+			}
+			errs[i] = Retry(ctx, build(input), opts...)
+			return nil
+		})
+	}
+	g.Wait()
+	return errs
+}
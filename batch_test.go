@@ -0,0 +1,172 @@
+package retryflow_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Vealcoo/retryflow"
+)
+
+func TestForEachErrorRateThresholdAborts(t *testing.T) {
+	ctx := context.Background()
+	attempts := 0
+	items := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	var called []int
+
+	steps := retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) ([]int, error) {
+			attempts++
+			return items, nil
+		}),
+		retryflow.ForEach(func(ctx context.Context, item int) (int, error) {
+			called = append(called, item)
+			if item < 6 {
+				return 0, errors.New("dependency down")
+			}
+			return item * 2, nil
+		}).Do(new([]int)),
+	)
+
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithErrorRateThreshold(0.5, 5),
+		retryflow.WithMaxRetries(5),
+		retryflow.WithInitialBackoff(time.Millisecond),
+	)
+	var batchErr *retryflow.BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected BatchError, got %v", err)
+	}
+	if batchErr.Failed != 5 || batchErr.Skipped != 5 || batchErr.Total != 10 {
+		t.Errorf("expected 5 failed and 5 skipped of 10, got %d and %d of %d", batchErr.Failed, batchErr.Skipped, batchErr.Total)
+	}
+	if len(called) != 5 {
+		t.Errorf("expected the batch to stop after 5 failing items, called %v", called)
+	}
+	if attempts != 1 {
+		t.Errorf("expected the group to abort after 1 attempt, got %d", attempts)
+	}
+}
+
+func TestForEachRetriesBelowThreshold(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	var out []int
+
+	steps := retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) ([]int, error) {
+			return []int{1, 2, 3, 4}, nil
+		}),
+		retryflow.ForEach(func(ctx context.Context, item int) (int, error) {
+			calls++
+			if item == 4 && calls < 8 {
+				return 0, errors.New("flaky")
+			}
+			return item * 2, nil
+		}).Do(&out),
+	)
+
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithErrorRateThreshold(0.5, 4),
+		retryflow.WithInitialBackoff(time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(out) != 4 || out[3] != 8 {
+		t.Errorf("unexpected output: %v", out)
+	}
+}
+
+func TestRetryEach(t *testing.T) {
+	var running, peak atomic.Int32
+	build := func(n int) retryflow.Steps {
+		return retryflow.Seq(retryflow.Exec(func(ctx context.Context) error {
+			cur := running.Add(1)
+			defer running.Add(-1)
+			for {
+				p := peak.Load()
+				if cur <= p || peak.CompareAndSwap(p, cur) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			if n%3 == 0 {
+				return fmt.Errorf("input %d: %w", n, permanentErr{})
+			}
+			return nil
+		}))
+	}
+
+	inputs := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	errs := retryflow.RetryEach(context.Background(), inputs, build, 3)
+	if len(errs) != len(inputs) {
+		t.Fatalf("expected %d errors, got %d", len(inputs), len(errs))
+	}
+	for i, n := range inputs {
+		if n%3 == 0 {
+			if want := fmt.Sprintf("attempt 1, step 1: input %d: permanent", n); errs[i] == nil || errs[i].Error() != want {
+				t.Errorf("input %d: expected %q, got %v", n, want, errs[i])
+			}
+		} else if errs[i] != nil {
+			t.Errorf("input %d: expected no error, got %v", n, errs[i])
+		}
+	}
+	if p := peak.Load(); p > 3 || p < 2 {
+		t.Errorf("expected up to 3 flows at a time, got %d", p)
+	}
+}
+
+func TestRetryEachCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var started atomic.Int32
+
+	errs := retryflow.RetryEach(ctx, []int{1, 2, 3, 4}, func(int) retryflow.Steps {
+		return retryflow.Seq(retryflow.Exec(func(ctx context.Context) error {
+			started.Add(1)
+			cancel()
+			<-ctx.Done()
+			return ctx.Err()
+		}))
+	}, 1)
+	if started.Load() != 1 {
+		t.Errorf("expected only the first flow to start, got %d", started.Load())
+	}
+	for i, err := range errs {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("input %d: expected context.Canceled, got %v", i, err)
+		}
+	}
+}
+
+// Run with -race: the flows share the options and call the hook concurrently.
+func TestRetryEachSharedOptions(t *testing.T) {
+	inputs := []int{1, 2, 3, 4, 5, 6}
+	fails := make([]atomic.Int32, len(inputs))
+	var retries atomic.Int32
+
+	errs := retryflow.RetryEach(context.Background(), inputs, func(n int) retryflow.Steps {
+		return retryflow.Seq(retryflow.Exec(func(ctx context.Context) error {
+			if fails[n-1].Add(1) <= int32(n%3) {
+				return errors.New("fail")
+			}
+			return nil
+		}))
+	}, 3,
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithOnRetry(func(int, error) { retries.Add(1) }),
+	)
+	for i, n := range inputs {
+		if errs[i] != nil {
+			t.Errorf("input %d: expected no error, got %v", n, errs[i])
+		}
+	}
+	if got := retries.Load(); got != 6 {
+		t.Errorf("expected 6 retries across the flows, got %d", got)
+	}
+}
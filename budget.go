@@ -0,0 +1,60 @@
+package retryflow
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExhausted is returned, wrapping the last attempt error, when a
+// flow wants to retry but its WithRetryBudget budget has no token left.
+var ErrBudgetExhausted = errors.New("retry budget exhausted")
+
+// RetryBudget is a token bucket capping retries per unit of time across all
+// flows sharing it, to prevent retry storms. Every retry takes a token;
+// first attempts are free. It is safe for concurrent use.
+type RetryBudget struct {
+	mu       sync.Mutex
+	capacity int
+	tokens   int
+	every    time.Duration
+	last     time.Time
+}
+
+// NewRetryBudget returns a full RetryBudget holding capacity tokens and
+// refilling one token every refillEvery. A zero refillEvery never refills.
+func NewRetryBudget(capacity int, refillEvery time.Duration) *RetryBudget {
+	return &RetryBudget{capacity: capacity, tokens: capacity, every: refillEvery, last: time.Now()}
+}
+
+// Remaining returns the number of tokens currently available.
+func (b *RetryBudget) Remaining() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	return b.tokens
+}
+
+// take consumes a token, reporting false if none is left.
+func (b *RetryBudget) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	if b.tokens == 0 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (b *RetryBudget) refill() {
+	if b.every <= 0 {
+		return
+	}
+	n := int(time.Since(b.last) / b.every)
+	if n == 0 {
+		return
+	}
+	b.tokens = min(b.tokens+n, b.capacity)
+	b.last = b.last.Add(time.Duration(n) * b.every)
+}
@@ -0,0 +1,121 @@
+package retryflow_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Vealcoo/retryflow"
+)
+
+func TestRetryBudgetForcesEarlyGiveUp(t *testing.T) {
+	errFail := errors.New("fail")
+	budget := retryflow.NewRetryBudget(2, time.Hour)
+	attempts := 0
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			attempts++
+			return errFail
+		}),
+	),
+		retryflow.WithRetryBudget(budget),
+		retryflow.WithMaxRetries(10),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if !errors.Is(err, retryflow.ErrBudgetExhausted) || !errors.Is(err, errFail) {
+		t.Fatalf("expected ErrBudgetExhausted wrapping errFail, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected 1 attempt and 2 retries, got %d attempts", attempts)
+	}
+}
+
+func TestRetryBudgetShared(t *testing.T) {
+	const flows, tokens = 5, 4
+	budget := retryflow.NewRetryBudget(tokens, 0)
+	var attempts atomic.Int32
+
+	var wg sync.WaitGroup
+	for range flows {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = retryflow.Retry(context.Background(), retryflow.Seq(
+				retryflow.Exec(func(ctx context.Context) error {
+					attempts.Add(1)
+					return errors.New("fail")
+				}),
+			),
+				retryflow.WithRetryBudget(budget),
+				retryflow.WithMaxRetries(10),
+				retryflow.WithInitialBackoff(time.Millisecond),
+				retryflow.WithJitter(0),
+			)
+		}()
+	}
+	wg.Wait()
+
+	if retries := int(attempts.Load()) - flows; retries != tokens {
+		t.Errorf("expected %d retries across all flows, got %d", tokens, retries)
+	}
+	if budget.Remaining() != 0 {
+		t.Errorf("expected an empty budget, got %d tokens", budget.Remaining())
+	}
+}
+
+func TestRetryBudgetRefills(t *testing.T) {
+	budget := retryflow.NewRetryBudget(1, 10*time.Millisecond)
+	_ = retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return errors.New("fail") }),
+	), retryflow.WithRetryBudget(budget), retryflow.WithInitialBackoff(time.Millisecond), retryflow.WithJitter(0))
+	time.Sleep(25 * time.Millisecond)
+	if got := budget.Remaining(); got != 1 {
+		t.Errorf("expected the budget to refill to its capacity of 1, got %d", got)
+	}
+}
+
+func TestRetryBudgetKeptOnGiveUp(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration // of the flow's context, if positive
+		opts    []retryflow.Option
+		reason  retryflow.GiveUpReason
+	}{
+		{"Schedule", 0,
+			[]retryflow.Option{retryflow.WithScheduleGuard(func(time.Time) (bool, time.Duration) { return false, 0 })},
+			retryflow.GiveUpSchedule},
+		{"Deadline", time.Second,
+			[]retryflow.Option{retryflow.WithInitialBackoff(time.Hour), retryflow.WithMaxBackoff(time.Hour)},
+			retryflow.GiveUpDeadline},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.timeout > 0 {
+				ctx, cancel = context.WithTimeout(context.Background(), tt.timeout)
+			}
+			defer cancel()
+			budget := retryflow.NewRetryBudget(1, 0)
+			var reason retryflow.GiveUpReason
+			opts := append([]retryflow.Option{
+				retryflow.WithRetryBudget(budget),
+				retryflow.WithJitter(0),
+				retryflow.WithOnGiveUp(func(_ int, _ error, r retryflow.GiveUpReason) { reason = r }),
+			}, tt.opts...)
+			_ = retryflow.Retry(ctx, retryflow.Seq(
+				retryflow.Exec(func(ctx context.Context) error { return errors.New("fail") }),
+			), opts...)
+			if reason != tt.reason {
+				t.Errorf("expected reason %v, got %v", tt.reason, reason)
+			}
+			if budget.Remaining() != 1 {
+				t.Errorf("expected the token to be kept, got %d tokens", budget.Remaining())
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package retryflow
+
+import (
+	"context"
+	"sync"
+)
+
+// Canceler stops running flows from outside their context, for APIs that
+// hand a running flow to another component. Register it with WithCanceler.
+// The zero value is ready to use.
+type Canceler struct {
+	mu       sync.Mutex
+	cancels  map[uint64]context.CancelFunc // registered flows by id
+	nextID   uint64
+	canceled bool
+}
+
+// Cancel stops every flow registered with c, which returns context.Canceled
+// like on cancellation of its context. A flow registered after Cancel stops
+// immediately.
+func (c *Canceler) Cancel() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.canceled = true
+	for _, cancel := range c.cancels {
+		cancel()
+	}
+}
+
+// attach derives a context from ctx that c cancels, and registers it until
+// the returned function is called.
+func (c *Canceler) attach(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.canceled {
+		cancel()
+	}
+	if c.cancels == nil {
+		c.cancels = make(map[uint64]context.CancelFunc)
+	}
+	id := c.nextID
+	c.nextID++
+	c.cancels[id] = cancel
+	return ctx, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.cancels, id)
+		cancel()
+	}
+}
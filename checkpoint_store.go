@@ -0,0 +1,87 @@
+package retryflow
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// CheckpointStore persists the checkpoint of a flow so that it can resume
+// after a process restart. See WithCheckpointStore.
+//
+// Outputs are passed as is; a store that serializes them must decode them
+// back into the type the step after the checkpoint expects, e.g. by
+// registering concrete types with encoding/gob.
+type CheckpointStore interface {
+	// Save records that the steps up to the 1-based index idx succeeded,
+	// the last of them producing output. idx 0 clears the checkpoint.
+	Save(ctx context.Context, key string, idx int, output any) error
+	// Load returns the checkpoint saved under key, or 0 and nil if there
+	// is none.
+	Load(ctx context.Context, key string) (idx int, output any, err error)
+}
+
+// MemoryCheckpointStore is a CheckpointStore keeping checkpoints in memory,
+// for tests and for resuming within a single process. Outputs are stored as
+// is, without serialization. The zero value is ready to use and it is safe
+// for concurrent use.
+type MemoryCheckpointStore struct {
+	mu    sync.Mutex
+	saved map[string]memoryCheckpoint
+}
+
+type memoryCheckpoint struct {
+	idx    int
+	output any
+}
+
+// Save implements CheckpointStore.
+func (s *MemoryCheckpointStore) Save(ctx context.Context, key string, idx int, output any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if idx == 0 {
+		delete(s.saved, key)
+		return nil
+	}
+	if s.saved == nil {
+		s.saved = make(map[string]memoryCheckpoint)
+	}
+	s.saved[key] = memoryCheckpoint{idx, output}
+	return nil
+}
+
+// Load implements CheckpointStore.
+func (s *MemoryCheckpointStore) Load(ctx context.Context, key string) (int, any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.saved[key]
+	return c.idx, c.output, nil
+}
+
+// loadCheckpoint initializes state from the checkpoint store, if any.
+func (o *options) loadCheckpoint(ctx context.Context, steps Steps, state *flowState) error {
+	if o.checkpointStore == nil || state.checkpoint > 0 {
+		return nil
+	}
+	idx, output, err := o.checkpointStore.Load(ctx, o.checkpointKey)
+	if err != nil {
+		return fmt.Errorf("load checkpoint: %w", err)
+	}
+	if idx < 0 || idx > len(steps) {
+		return fmt.Errorf("load checkpoint: step %d out of range for %d steps", idx, len(steps))
+	}
+	state.checkpoint = idx
+	state.checkpointOutput = output
+	return nil
+}
+
+// saveCheckpoint records a committed checkpoint in the checkpoint store, if any.
+func (o *options) saveCheckpoint(ctx context.Context, idx int, output any) error {
+	if o.checkpointStore == nil {
+		return nil
+	}
+	if err := o.checkpointStore.Save(ctx, o.checkpointKey, idx, output); err != nil {
+		return fmt.Errorf("save checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package retryflow_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Vealcoo/retryflow"
+)
+
+func TestMemoryCheckpointStore(t *testing.T) {
+	ctx := context.Background()
+	var store retryflow.MemoryCheckpointStore
+
+	if idx, output, err := store.Load(ctx, "k"); idx != 0 || output != nil || err != nil {
+		t.Fatalf("expected no checkpoint, got %d, %v, %v", idx, output, err)
+	}
+	if err := store.Save(ctx, "k", 2, "out"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if idx, output, _ := store.Load(ctx, "k"); idx != 2 || output != "out" {
+		t.Errorf("expected checkpoint 2 with output out, got %d with %v", idx, output)
+	}
+	if idx, _, _ := store.Load(ctx, "other"); idx != 0 {
+		t.Errorf("expected keys to be independent, got checkpoint %d", idx)
+	}
+	if err := store.Save(ctx, "k", 0, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if idx, output, _ := store.Load(ctx, "k"); idx != 0 || output != nil {
+		t.Errorf("expected the checkpoint to be cleared, got %d with %v", idx, output)
+	}
+}
+
+func TestCheckpointStoreResumesAfterRestart(t *testing.T) {
+	ctx := context.Background()
+	store := &retryflow.MemoryCheckpointStore{}
+	var runs []int
+	crash := true
+	newSteps := func() retryflow.Steps {
+		return retryflow.Seq(
+			retryflow.Chain(func(ctx context.Context, _ any) (int, error) {
+				runs = append(runs, 1)
+				return 1, nil
+			}),
+			retryflow.Chain(func(ctx context.Context, in int) (int, error) {
+				runs = append(runs, 2)
+				return in + 1, nil
+			}).Checkpoint(),
+			retryflow.Chain(func(ctx context.Context, in int) (int, error) {
+				runs = append(runs, 3)
+				if crash {
+					return 0, permanentErr{}
+				}
+				return in + 1, nil
+			}),
+		)
+	}
+	opts := []retryflow.Option{
+		retryflow.WithCheckpointStore(store, "order-42"),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	}
+
+	if err := retryflow.Retry(ctx, newSteps(), opts...); err == nil {
+		t.Fatal("expected the first run to fail")
+	}
+	if idx, output, _ := store.Load(ctx, "order-42"); idx != 2 || output != 2 {
+		t.Fatalf("expected checkpoint 2 with output 2 saved, got %d with %v", idx, output)
+	}
+
+	// Simulate a restart: new steps, same store
+	crash = false
+	runs = nil
+	var result int
+	steps := newSteps()
+	steps[2].Do(&result)
+	if err := retryflow.Retry(ctx, steps, opts...); err != nil {
+		t.Fatalf("expected no error after restart, got %v", err)
+	}
+	if len(runs) != 1 || runs[0] != 3 || result != 3 {
+		t.Errorf("expected only step 3 to run with the saved output, got runs %v and result %d", runs, result)
+	}
+}
+
+func TestCheckpointStoreLoadError(t *testing.T) {
+	errLoad := errors.New("unavailable")
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return nil }),
+	), retryflow.WithCheckpointStore(failingStore{errLoad}, "k"))
+	if !errors.Is(err, errLoad) {
+		t.Errorf("expected load error, got %v", err)
+	}
+}
+
+type failingStore struct{ err error }
+
+func (s failingStore) Save(context.Context, string, int, any) error { return s.err }
+func (s failingStore) Load(context.Context, string) (int, any, error) {
+	return 0, nil, s.err
+}
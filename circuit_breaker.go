@@ -0,0 +1,65 @@
+package retryflow
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when the WithCircuitBreaker breaker rejects an
+// attempt, without running any step.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitBreaker decides whether attempts may run, usually shared by many
+// flows calling the same dependency. Retry consults Allow before each
+// attempt, calls RecordSuccess for every successful step and RecordFailure
+// for every retryable failure.
+type CircuitBreaker interface {
+	Allow() bool
+	RecordSuccess()
+	RecordFailure()
+}
+
+// CountBreaker is a CircuitBreaker that opens after a number of consecutive
+// failures and rejects attempts until a cooldown has passed. It then lets
+// attempts through again: a success closes it, while a failure reopens it
+// for another cooldown.
+type CountBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+}
+
+// NewCountBreaker returns a CountBreaker opening after threshold consecutive
+// failures for cooldown.
+func NewCountBreaker(threshold int, cooldown time.Duration) *CountBreaker {
+	return &CountBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// Allow reports whether the breaker is closed or its cooldown has passed.
+func (b *CountBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !time.Now().Before(b.openUntil)
+}
+
+// RecordSuccess closes the breaker and resets the failure count.
+func (b *CountBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.openUntil = time.Time{}
+}
+
+// RecordFailure counts a failure, opening the breaker for the cooldown once
+// threshold consecutive failures are reached.
+func (b *CountBreaker) RecordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
@@ -0,0 +1,66 @@
+package retryflow_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Vealcoo/retryflow"
+)
+
+func TestCircuitBreakerOpensAfterFailures(t *testing.T) {
+	ctx := context.Background()
+	cb := retryflow.NewCountBreaker(3, time.Hour)
+	attempts := 0
+	steps := retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			attempts++
+			return errors.New("down")
+		}),
+	)
+	opts := []retryflow.Option{
+		retryflow.WithCircuitBreaker(cb),
+		retryflow.WithMaxRetries(10),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	}
+
+	if err := retryflow.Retry(ctx, steps, opts...); !errors.Is(err, retryflow.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected the breaker to open after 3 attempts, got %d", attempts)
+	}
+
+	// The open breaker blocks other flows without running their steps
+	if err := retryflow.Retry(ctx, steps, opts...); !errors.Is(err, retryflow.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected no attempt while the breaker is open, got %d", attempts)
+	}
+}
+
+func TestCircuitBreakerCooldown(t *testing.T) {
+	cb := retryflow.NewCountBreaker(1, 20*time.Millisecond)
+	cb.RecordFailure()
+	if cb.Allow() {
+		t.Fatal("expected the breaker to be open")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if !cb.Allow() {
+		t.Fatal("expected the breaker to allow attempts after the cooldown")
+	}
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return nil }),
+	), retryflow.WithCircuitBreaker(cb))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	cb.RecordFailure()
+	if cb.Allow() {
+		t.Error("expected a failure after closing to reopen the breaker with threshold 1")
+	}
+}
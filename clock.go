@@ -0,0 +1,71 @@
+package retryflow
+
+import (
+	"context"
+	"time"
+)
+
+// Clock is the time source used by Retry for elapsed time and backoff sleeps.
+// Tests can inject their own implementation with WithClock.
+type Clock interface {
+	Now() time.Time
+	// Sleep blocks for d, returning ctx.Err() if ctx is done first.
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// realClock is the default Clock backed by the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// readinessPollInterval is how often a WithReadinessProbe probe is polled.
+const readinessPollInterval = 10 * time.Millisecond
+
+// sleep waits for d on the configured clock. It returns early without error
+// when the wakeup channel fires or the readiness probe reports true, and with
+// ctx.Err() when ctx is done.
+func (o *options) sleep(ctx context.Context, d time.Duration) error {
+	if o.wakeup == nil && o.readinessProbe == nil {
+		return o.clock.Sleep(ctx, d)
+	}
+
+	sleepCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		var tick <-chan time.Time
+		if o.readinessProbe != nil {
+			t := time.NewTicker(readinessPollInterval)
+			defer t.Stop()
+			tick = t.C
+		}
+		for {
+			select {
+			case <-o.wakeup:
+				cancel()
+				return
+			case <-tick:
+				if o.readinessProbe(sleepCtx) {
+					cancel()
+					return
+				}
+			case <-sleepCtx.Done():
+				return
+			}
+		}
+	}()
+	if err := o.clock.Sleep(sleepCtx, d); err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return nil
+}
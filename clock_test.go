@@ -0,0 +1,162 @@
+package retryflow_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Vealcoo/retryflow"
+	"github.com/Vealcoo/retryflow/retryflowtest"
+)
+
+func TestScheduleGuard(t *testing.T) {
+	peak := func(now time.Time) (bool, time.Duration) {
+		if h := now.Hour(); h >= 9 && h < 18 {
+			return false, 0
+		}
+		return true, 0
+	}
+
+	tests := []struct {
+		name         string
+		start        time.Time
+		wantAttempts int
+	}{
+		{"DeniedOncePeakStarts", time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC), 2},
+		{"AllowedOffPeak", time.Date(2025, 1, 1, 20, 0, 0, 0, time.UTC), 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := retryflow.Retry(context.Background(), retryflow.Seq(
+				retryflow.Exec(func(ctx context.Context) error {
+					attempts++
+					return errors.New("fail")
+				}),
+			),
+				retryflow.WithClock(retryflowtest.NewClock(tt.start)),
+				retryflow.WithScheduleGuard(peak),
+				retryflow.WithMaxRetries(3),
+				retryflow.WithInitialBackoff(time.Hour),
+				retryflow.WithMaxBackoff(time.Hour),
+				retryflow.WithBackoffStrategy(retryflow.ConstantBackoff),
+				retryflow.WithJitter(0),
+				retryflow.WithMaxElapsedTime(0),
+			)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("expected %d attempts, got %d", tt.wantAttempts, attempts)
+			}
+		})
+	}
+}
+
+func TestScheduleGuardDelay(t *testing.T) {
+	clock := retryflowtest.NewClock(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	var attemptTimes []time.Time
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			attemptTimes = append(attemptTimes, clock.Now())
+			if len(attemptTimes) == 1 {
+				return errors.New("fail")
+			}
+			return nil
+		}),
+	),
+		retryflow.WithClock(clock),
+		retryflow.WithScheduleGuard(func(now time.Time) (bool, time.Duration) {
+			offPeak := time.Date(now.Year(), now.Month(), now.Day(), 18, 0, 0, 0, now.Location())
+			return true, offPeak.Sub(now)
+		}),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithMaxElapsedTime(0),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := attemptTimes[1].Hour(); got != 18 {
+		t.Errorf("expected retry deferred to 18:00, got %v", attemptTimes[1])
+	}
+}
+
+func TestRecordedSleeps(t *testing.T) {
+	clock := retryflowtest.NewClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return errors.New("fail") }),
+	),
+		retryflow.WithClock(clock),
+		retryflow.WithMaxRetries(6),
+		retryflow.WithInitialBackoff(10*time.Millisecond),
+		retryflow.WithMaxBackoff(100*time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	want := []time.Duration{
+		10 * time.Millisecond,
+		20 * time.Millisecond,
+		40 * time.Millisecond,
+		80 * time.Millisecond,
+		100 * time.Millisecond,
+	}
+	if got := clock.Sleeps(); !slices.Equal(got, want) {
+		t.Errorf("expected sleeps %v, got %v", want, got)
+	}
+}
+
+func TestDeadlineBecomesBudget(t *testing.T) {
+	clock := retryflowtest.NewClock(time.Now())
+	deadline := clock.Now().Add(350 * time.Millisecond)
+	attempts := 0
+	var budgets []time.Duration
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			attempts++
+			b, _ := retryflow.RemainingBudget(ctx)
+			budgets = append(budgets, b)
+			return errors.New("fail")
+		}),
+	),
+		retryflow.WithClock(clock),
+		retryflow.WithDeadline(deadline),
+		retryflow.WithMaxRetries(-1),
+		retryflow.WithMaxElapsedTime(0),
+		retryflow.WithInitialBackoff(100*time.Millisecond),
+		retryflow.WithBackoffStrategy(retryflow.ConstantBackoff),
+		retryflow.WithJitter(0),
+	)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	// Attempts start at 0, 100, 200, 300 and 400ms, the last one past the budget
+	if attempts != 5 {
+		t.Errorf("expected 5 attempts, got %d", attempts)
+	}
+	want := []time.Duration{350 * time.Millisecond, 250 * time.Millisecond, 150 * time.Millisecond, 50 * time.Millisecond, 0}
+	if fmt.Sprint(budgets) != fmt.Sprint(want) {
+		t.Errorf("expected remaining budgets %v, got %v", want, budgets)
+	}
+}
+
+func TestDeadlineAlreadyPassed(t *testing.T) {
+	clock := retryflowtest.NewClock(time.Now())
+	ran := false
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { ran = true; return nil }),
+	), retryflow.WithClock(clock), retryflow.WithDeadline(clock.Now().Add(-time.Second)))
+	if !errors.Is(err, context.DeadlineExceeded) || ran {
+		t.Errorf("expected context.DeadlineExceeded without running steps, got %v (ran %v)", err, ran)
+	}
+}
@@ -0,0 +1,74 @@
+package retryflow
+
+import (
+	"math"
+	"reflect"
+)
+
+// coerce converts v to type t when the conversion is lossless: numeric to
+// numeric when the value fits t, or between types sharing an underlying
+// type. A float converts to an integer type only if it is a whole number.
+// Numeric to string conversions are refused since Go treats them as runes.
+func coerce(v any, t reflect.Type) (any, bool) {
+	if v == nil {
+		return nil, false
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Type().AssignableTo(t) {
+		return v, true
+	}
+	if !rv.Type().ConvertibleTo(t) {
+		return nil, false
+	}
+	if isNumeric(rv.Kind()) != isNumeric(t.Kind()) {
+		return nil, false
+	}
+	if isNumeric(t.Kind()) && !fits(rv, t) {
+		return nil, false
+	}
+	return rv.Convert(t).Interface(), true
+}
+
+// fits reports whether the numeric value rv converts to the numeric type t
+// without overflowing or dropping a fraction.
+func fits(rv reflect.Value, t reflect.Type) bool {
+	dst := reflect.New(t).Elem()
+	switch {
+	case rv.CanInt():
+		n := rv.Int()
+		switch {
+		case dst.CanInt():
+			return !dst.OverflowInt(n)
+		case dst.CanUint():
+			return n >= 0 && !dst.OverflowUint(uint64(n))
+		}
+		return !dst.OverflowFloat(float64(n))
+	case rv.CanUint():
+		n := rv.Uint()
+		switch {
+		case dst.CanInt():
+			return n <= math.MaxInt64 && !dst.OverflowInt(int64(n))
+		case dst.CanUint():
+			return !dst.OverflowUint(n)
+		}
+		return !dst.OverflowFloat(float64(n))
+	}
+	f := rv.Float()
+	switch {
+	case dst.CanInt():
+		return f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 && !dst.OverflowInt(int64(f))
+	case dst.CanUint():
+		return f == math.Trunc(f) && f >= 0 && f < math.MaxUint64 && !dst.OverflowUint(uint64(f))
+	}
+	return !dst.OverflowFloat(f)
+}
+
+func isNumeric(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
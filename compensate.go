@@ -0,0 +1,49 @@
+package retryflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// defaultCompensationGrace bounds compensations that run after the flow's
+// context is done, unless WithCompensationContext is set.
+const defaultCompensationGrace = 5 * time.Second
+
+// completed records a successful run of a step with a Compensate handler.
+type completed struct {
+	step   int // 1-based
+	output any
+}
+
+// compensate runs the Compensate handlers of done in reverse order after
+// the flow failed with err. Their errors are joined to err. When ctx is
+// already done the handlers get a fresh context with a grace deadline.
+func (o *options) compensate(ctx context.Context, steps Steps, done []completed, err error) error {
+	if len(done) == 0 {
+		return err
+	}
+	cctx := ctx
+	if ctx.Err() != nil {
+		if o.compensationContext != nil {
+			cctx = o.compensationContext()
+		} else {
+			var cancel context.CancelFunc
+			cctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), defaultCompensationGrace)
+			defer cancel()
+		}
+	}
+
+	errs := []error{err}
+	for i := len(done) - 1; i >= 0; i-- {
+		c := done[i]
+		if cerr := steps[c.step-1].compensate(cctx, c.output); cerr != nil {
+			errs = append(errs, fmt.Errorf("compensate step %d: %w", c.step, cerr))
+		}
+	}
+	if len(errs) == 1 {
+		return err
+	}
+	return errors.Join(errs...)
+}
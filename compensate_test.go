@@ -0,0 +1,124 @@
+package retryflow_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Vealcoo/retryflow"
+)
+
+func TestCompensationRunsOnCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var compensated any
+	var compErr error
+	var hasDeadline bool
+	steps := retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (string, error) {
+			return "reservation-1", nil
+		}).Compensate(func(ctx context.Context, output any) error {
+			compensated = output
+			compErr = ctx.Err()
+			_, hasDeadline = ctx.Deadline()
+			return nil
+		}),
+		retryflow.Exec(func(ctx context.Context) error {
+			cancel()
+			return ctx.Err()
+		}),
+	)
+
+	err := retryflow.Retry(ctx, steps, retryflow.WithInitialBackoff(time.Millisecond), retryflow.WithJitter(0))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if compensated != "reservation-1" {
+		t.Fatalf("expected compensation with the step output, got %v", compensated)
+	}
+	if compErr != nil || !hasDeadline {
+		t.Errorf("expected a live grace context with a deadline, got err %v and deadline %v", compErr, hasDeadline)
+	}
+}
+
+func TestCompensationContext(t *testing.T) {
+	type key struct{}
+	ctx, cancel := context.WithCancel(context.Background())
+	var got any
+	steps := retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return nil }).
+			Compensate(func(ctx context.Context, _ any) error {
+				got = ctx.Value(key{})
+				return nil
+			}),
+		retryflow.Exec(func(ctx context.Context) error {
+			cancel()
+			return ctx.Err()
+		}),
+	)
+
+	_ = retryflow.Retry(ctx, steps,
+		retryflow.WithCompensationContext(func() context.Context {
+			return context.WithValue(context.Background(), key{}, "cleanup")
+		}),
+	)
+	if got != "cleanup" {
+		t.Errorf("expected the WithCompensationContext context, got value %v", got)
+	}
+}
+
+// sagaSteps returns three compensable steps outputting 1, 2 and 3, the
+// second one a checkpoint, followed by a permanently failing step.
+func sagaSteps(compensated *[]any, compErr error) retryflow.Steps {
+	compensable := func(n int) *retryflow.Step {
+		return retryflow.Chain(func(ctx context.Context, _ any) (int, error) {
+			return n, nil
+		}).Compensate(func(ctx context.Context, output any) error {
+			*compensated = append(*compensated, output)
+			return compErr
+		})
+	}
+	return retryflow.Seq(
+		compensable(1),
+		compensable(2).Checkpoint(),
+		compensable(3),
+		retryflow.Exec(func(ctx context.Context) error { return permanentErr{} }),
+	)
+}
+
+func TestCompensateSinceCheckpoint(t *testing.T) {
+	var compensated []any
+	err := retryflow.Retry(context.Background(), sagaSteps(&compensated, nil))
+	if !errors.As(err, new(permanentErr)) {
+		t.Fatalf("expected the step error, got %v", err)
+	}
+	if len(compensated) != 1 || compensated[0] != 3 {
+		t.Errorf("expected only the step after the checkpoint to be compensated, got %v", compensated)
+	}
+}
+
+func TestCompensateAllInReverse(t *testing.T) {
+	var compensated []any
+	_ = retryflow.Retry(context.Background(), sagaSteps(&compensated, nil), retryflow.WithCompensateAll(true))
+	if len(compensated) != 3 || compensated[0] != 3 || compensated[1] != 2 || compensated[2] != 1 {
+		t.Errorf("expected compensations of outputs 3, 2, 1, got %v", compensated)
+	}
+}
+
+func TestCompensationErrorsJoined(t *testing.T) {
+	errUndo := errors.New("undo failed")
+	var compensated []any
+	err := retryflow.Retry(context.Background(), sagaSteps(&compensated, errUndo), retryflow.WithCompensateAll(true))
+
+	var attemptErr *retryflow.AttemptError
+	if !errors.As(err, &attemptErr) || attemptErr.Step != 4 {
+		t.Errorf("expected the step 4 attempt error, got %v", err)
+	}
+	if !errors.Is(err, errUndo) {
+		t.Errorf("expected compensation errors joined to the returned error, got %v", err)
+	}
+	if len(compensated) != 3 {
+		t.Errorf("expected every compensation to run despite errors, got %v", compensated)
+	}
+}
@@ -0,0 +1,123 @@
+package retryflow
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// ConfigError is returned by Retry and the other entry points when an
+// option or the steps fail validation, before any step runs, including
+// warnings under WithStrictValidation. Field names the invalid setting, such
+// as "initialBackoff", or is "steps" for the step sequence.
+type ConfigError struct {
+	Field string
+	Err   error
+}
+
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigSnapshot is a copy of the scalar settings a flow runs with, meant
+// for logging. Function-valued options such as hooks are left out.
+type ConfigSnapshot struct {
+	InitialBackoff              time.Duration
+	MaxBackoff                  time.Duration
+	Jitter                      time.Duration
+	JitterFraction              float64
+	JitterMode                  JitterMode
+	DecorrelatedJitterBase      time.Duration
+	DecorrelatedJitterCap       time.Duration
+	AdaptiveJitter              bool
+	JitterClasses               []ErrorClass
+	RetryableClasses            []ErrorClass
+	RestartClasses              []ErrorClass
+	MinBackoffByClass           map[ErrorClass]time.Duration
+	LoadFactor                  float64
+	MaxRetries                  int
+	MaxElapsedTime              time.Duration
+	Deadline                    time.Time
+	MaxIterations               int
+	MaxQueuedRetries            int
+	PerErrorLimits              map[ErrorClass]int
+	MaxDistinctErrorClasses     int
+	MultiErrorPolicy            MultiErrorPolicy
+	ErrorRateThreshold          float64
+	ErrorRateMinSamples         int
+	AutoCheckpointEvery         int
+	CheckpointCooldown          time.Duration
+	SameOutputStep              int
+	MaxSameOutput               int
+	WholeFlowRetry              bool
+	CompensateAll               bool
+	CaptureSteps                []int
+	MaxEventHistory             int
+	FlowKey                     string
+	FlowName                    string
+	IdempotencyKey              string
+	OutputCoercion              bool
+	StrictValidation            bool
+	RecoverPanic                bool
+	PanicClass                  ErrorClass
+	ResetErrorLimitOnCheckpoint bool
+	ResetBackoffOnClassChange   bool
+	CollapseRepeatedRetries     bool
+}
+
+func (c ConfigSnapshot) String() string {
+	type plain ConfigSnapshot
+	return fmt.Sprintf("%+v", plain(c))
+}
+
+// snapshot returns the scalar settings of o.
+func (o *options) snapshot() ConfigSnapshot {
+	return ConfigSnapshot{
+		InitialBackoff:              o.initialBackoff,
+		MaxBackoff:                  o.maxBackoff,
+		Jitter:                      o.jitter,
+		JitterFraction:              o.jitterFraction,
+		JitterMode:                  o.jitterMode,
+		DecorrelatedJitterBase:      o.decorrelatedBase,
+		DecorrelatedJitterCap:       o.decorrelatedCap,
+		AdaptiveJitter:              o.adaptiveJitter,
+		JitterClasses:               slices.Sorted(maps.Keys(o.jitterClasses)),
+		RetryableClasses:            slices.Sorted(maps.Keys(o.retryableClasses)),
+		RestartClasses:              slices.Sorted(maps.Keys(o.restartClasses)),
+		MinBackoffByClass:           maps.Clone(o.minBackoffByClass),
+		LoadFactor:                  o.loadFactor,
+		MaxRetries:                  o.maxRetries,
+		MaxElapsedTime:              o.maxElapsedTime,
+		Deadline:                    o.deadline,
+		MaxIterations:               o.maxIterations,
+		MaxQueuedRetries:            o.maxQueued,
+		PerErrorLimits:              maps.Clone(o.perErrorLimits),
+		MaxDistinctErrorClasses:     o.maxDistinctClasses,
+		MultiErrorPolicy:            o.multiErrorPolicy,
+		ErrorRateThreshold:          o.errorRateThreshold,
+		ErrorRateMinSamples:         o.errorRateMinSamples,
+		AutoCheckpointEvery:         o.autoCheckpointEvery,
+		CheckpointCooldown:          o.checkpointCooldown,
+		SameOutputStep:              o.sameOutputStep,
+		MaxSameOutput:               o.maxSameOutput,
+		WholeFlowRetry:              o.wholeFlowRetry,
+		CompensateAll:               o.compensateAll,
+		CaptureSteps:                slices.Sorted(maps.Keys(o.captureSteps)),
+		MaxEventHistory:             o.maxEventHistory,
+		FlowKey:                     o.flowKey,
+		FlowName:                    o.flowName,
+		IdempotencyKey:              o.idempotencyKey,
+		OutputCoercion:              o.outputCoercion,
+		StrictValidation:            o.strictValidation,
+		RecoverPanic:                o.recoverPanic,
+		PanicClass:                  o.panicClass,
+		ResetErrorLimitOnCheckpoint: o.resetErrorLimitOnCheckpoint,
+		ResetBackoffOnClassChange:   o.resetBackoffOnClassChange,
+		CollapseRepeatedRetries:     o.collapseRetries,
+	}
+}
@@ -0,0 +1,216 @@
+package retryflow_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Vealcoo/retryflow"
+)
+
+func TestOnStartSnapshot(t *testing.T) {
+	var snap retryflow.ConfigSnapshot
+	calls := 0
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return nil }),
+	),
+		retryflow.WithMaxRetries(7),
+		retryflow.WithInitialBackoff(50*time.Millisecond),
+		retryflow.WithMaxBackoff(2*time.Second),
+		retryflow.WithJitter(10*time.Millisecond),
+		retryflow.WithPerErrorLimits(retryflow.NewErrorClassLimit().AddLimit(retryflow.ClassRateLimit, 2)),
+		retryflow.WithOnStart(func(config retryflow.ConfigSnapshot) {
+			calls++
+			snap = config
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected OnStart to fire once, got %d", calls)
+	}
+	if snap.MaxRetries != 7 || snap.InitialBackoff != 50*time.Millisecond ||
+		snap.MaxBackoff != 2*time.Second || snap.Jitter != 10*time.Millisecond {
+		t.Errorf("snapshot does not reflect overrides: %v", snap)
+	}
+	if snap.MaxElapsedTime != 5*time.Minute {
+		t.Errorf("expected default maxElapsedTime, got %v", snap.MaxElapsedTime)
+	}
+	if snap.PerErrorLimits[retryflow.ClassRateLimit] != 2 {
+		t.Errorf("expected ratelimit limit 2, got %v", snap.PerErrorLimits)
+	}
+	if s := snap.String(); !strings.Contains(s, "MaxRetries:7") || !strings.Contains(s, "InitialBackoff:50ms") {
+		t.Errorf("unexpected string form: %s", s)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	policy := retryflow.NewPolicy(
+		retryflow.WithMaxRetries(3),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithPerErrorLimits(retryflow.NewErrorClassLimit().AddLimit(retryflow.ClassAuth, 1)),
+	)
+	if policy.MaxRetries != 3 || policy.InitialBackoff != time.Millisecond || policy.MaxBackoff != 30*time.Second {
+		t.Fatalf("unexpected policy fields: %+v", policy)
+	}
+	if policy.PerErrorLimits[retryflow.ClassAuth] != 1 {
+		t.Errorf("expected auth limit 1, got %v", policy.PerErrorLimits)
+	}
+	if policy.MaxRetries > 10 {
+		t.Error("policy violates the audit rule")
+	}
+
+	attempts := 0
+	var snap retryflow.ConfigSnapshot
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			attempts++
+			return errors.New("fail")
+		}),
+	),
+		retryflow.WithMaxRetries(8), // overridden by the policy
+		retryflow.WithPolicy(policy),
+		retryflow.WithJitter(0), // overrides the policy
+		retryflow.WithOnStart(func(c retryflow.ConfigSnapshot) { snap = c }),
+	)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if attempts != 3 {
+		t.Errorf("expected the policy's 3 attempts, got %d", attempts)
+	}
+	if snap.Jitter != 0 || snap.PerErrorLimits[retryflow.ClassAuth] != 1 {
+		t.Errorf("expected the policy merged with later options, got %v", snap)
+	}
+}
+
+func TestConfigError(t *testing.T) {
+	tests := []struct {
+		field string
+		opts  []retryflow.Option
+	}{
+		{"initialBackoff", []retryflow.Option{retryflow.WithInitialBackoff(0)}},
+		{"maxBackoff", []retryflow.Option{retryflow.WithInitialBackoff(time.Second), retryflow.WithMaxBackoff(time.Millisecond)}},
+		{"jitter", []retryflow.Option{retryflow.WithJitter(-time.Millisecond)}},
+		{"jitterFraction", []retryflow.Option{retryflow.WithPolicy(func() retryflow.Policy {
+			p := retryflow.NewPolicy()
+			p.JitterFraction = 1.5
+			return p
+		}())}},
+		{"maxElapsedTime", []retryflow.Option{retryflow.WithMaxRetries(-1), retryflow.WithMaxElapsedTime(0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			ran := false
+			err := retryflow.Retry(context.Background(), retryflow.Seq(
+				retryflow.Exec(func(ctx context.Context) error { ran = true; return nil }),
+			), tt.opts...)
+			var cerr *retryflow.ConfigError
+			if !errors.As(err, &cerr) {
+				t.Fatalf("expected a *ConfigError, got %v", err)
+			}
+			if cerr.Field != tt.field {
+				t.Errorf("expected field %q, got %q", tt.field, cerr.Field)
+			}
+			if !strings.Contains(err.Error(), tt.field) {
+				t.Errorf("expected the message to name %s, got %q", tt.field, err)
+			}
+			if ran {
+				t.Error("expected no step to run")
+			}
+		})
+	}
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return permanentErr{} }),
+	))
+	var cerr *retryflow.ConfigError
+	if errors.As(err, &cerr) {
+		t.Errorf("expected a step error not to be a *ConfigError, got %v", err)
+	}
+}
+
+func TestConfigErrorForSteps(t *testing.T) {
+	noop := func(ctx context.Context) error { return nil }
+	tests := []struct {
+		name  string
+		field string
+		steps retryflow.Steps
+		opts  []retryflow.Option
+	}{
+		{"UnknownLabel", "steps", retryflow.Seq(retryflow.Exec(noop).RetryFrom("missing")), nil},
+		{"StrictTrailingCheckpoint", "steps", retryflow.Seq(retryflow.Exec(noop), retryflow.Exec(noop).Checkpoint()),
+			[]retryflow.Option{retryflow.WithStrictValidation(true)}},
+		{"StrictJitter", "jitter", retryflow.Seq(retryflow.Exec(noop)),
+			[]retryflow.Option{retryflow.WithStrictValidation(true), retryflow.WithJitter(time.Second)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := false
+			opts := append(tt.opts, retryflow.WithOnStart(func(retryflow.ConfigSnapshot) { started = true }))
+			err := retryflow.Retry(context.Background(), tt.steps, opts...)
+			var cerr *retryflow.ConfigError
+			if !errors.As(err, &cerr) || cerr.Field != tt.field {
+				t.Fatalf("expected a *ConfigError for %s, got %v", tt.field, err)
+			}
+			if started {
+				t.Error("expected the flow not to start")
+			}
+		})
+	}
+}
+
+func TestSnapshotScalarFields(t *testing.T) {
+	deadline := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		opt     retryflow.Option
+		check   func(p retryflow.Policy) bool
+		perFlow bool // identifies a single flow, so WithPolicy leaves it out
+	}{
+		{"Deadline", retryflow.WithDeadline(deadline), func(p retryflow.Policy) bool { return p.Deadline.Equal(deadline) }, true},
+		{"LoadFactor", retryflow.WithLoadSource(func() float64 { return 0 }, 2.5), func(p retryflow.Policy) bool { return p.LoadFactor == 2.5 }, false},
+		{"StrictValidation", retryflow.WithStrictValidation(true), func(p retryflow.Policy) bool { return p.StrictValidation }, false},
+		{"IdempotencyKey", retryflow.WithIdempotencyKey("order-42"), func(p retryflow.Policy) bool { return p.IdempotencyKey == "order-42" }, true},
+		{"FlowKey", retryflow.WithFlowKey("order-42"), func(p retryflow.Policy) bool { return p.FlowKey == "order-42" }, true},
+		{"FlowName", retryflow.WithFlowName("checkout"), func(p retryflow.Policy) bool { return p.FlowName == "checkout" }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := retryflow.NewPolicy(tt.opt)
+			if !tt.check(p) {
+				t.Errorf("expected the snapshot to hold the setting, got %v", retryflow.ConfigSnapshot(p))
+			}
+			if again := retryflow.NewPolicy(retryflow.WithPolicy(p)); tt.check(again) == tt.perFlow {
+				t.Errorf("expected WithPolicy to apply the setting: %v, got %v", !tt.perFlow, retryflow.ConfigSnapshot(again))
+			}
+			// A shared policy keeps the flow's own setting
+			if tt.perFlow {
+				if kept := retryflow.NewPolicy(tt.opt, retryflow.WithPolicy(retryflow.NewPolicy())); !tt.check(kept) {
+					t.Errorf("expected WithPolicy to keep the flow's setting, got %v", retryflow.ConfigSnapshot(kept))
+				}
+			}
+		})
+	}
+}
+
+func TestPolicyRetryableClassesReplaceRetryable(t *testing.T) {
+	policy := retryflow.NewPolicy(retryflow.WithRetryableClasses(retryflow.ClassTransient))
+	attempts := 0
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { attempts++; return errors.New("fail") }),
+	),
+		retryflow.WithRetryable(func(error) bool { return true }),
+		retryflow.WithPolicy(policy),
+		retryflow.WithErrorClassifier(func(error) retryflow.ErrorClass { return retryflow.ClassPermanent }),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if err == nil || attempts != 1 {
+		t.Errorf("expected the policy's classes to stop retries after 1 attempt, got %d and %v", attempts, err)
+	}
+}
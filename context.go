@@ -0,0 +1,112 @@
+package retryflow
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// attemptKey is the context key for the attemptInfo of the running attempt.
+type attemptKey struct{}
+
+// stepKey is the context key for the 1-based index of the running step.
+type stepKey struct{}
+
+// attemptInfo describes the running attempt to its steps.
+type attemptInfo struct {
+	attempt          int
+	remainingRetries int // attempts left after this one, -1 if unlimited
+	start            time.Time
+	maxElapsedTime   time.Duration
+	clock            Clock
+	store            *sync.Map
+	stop             *atomic.Bool // set by StopRetrying
+	idempotencyKey   string
+	// WithErrorRateThreshold, checked by ForEach as its items run
+	errorRateThreshold  float64
+	errorRateMinSamples int
+}
+
+// errorRateExceeded reports whether failed of the run items of a batch are
+// enough to abort it under WithErrorRateThreshold.
+func (info *attemptInfo) errorRateExceeded(failed, run int) bool {
+	return info.errorRateThreshold > 0 && run >= info.errorRateMinSamples &&
+		float64(failed)/float64(run) > info.errorRateThreshold
+}
+
+// AttemptFromContext returns the number of the running attempt, counted
+// since the last checkpoint like the attempt numbers passed to hooks. The
+// result is false when ctx does not come from a running flow.
+func AttemptFromContext(ctx context.Context) (int, bool) {
+	info, ok := ctx.Value(attemptKey{}).(*attemptInfo)
+	if !ok {
+		return 0, false
+	}
+	return info.attempt, true
+}
+
+// StepFromContext returns the 1-based index of the running step. The result
+// is false when ctx does not come from a step of a running flow.
+func StepFromContext(ctx context.Context) (int, bool) {
+	step, ok := ctx.Value(stepKey{}).(int)
+	return step, ok
+}
+
+// RemainingRetries returns how many attempts are left after the current one
+// before maxRetries is reached. The result is false when retries are
+// unlimited or ctx does not come from a running flow.
+func RemainingRetries(ctx context.Context) (int, bool) {
+	info, ok := ctx.Value(attemptKey{}).(*attemptInfo)
+	if !ok || info.remainingRetries < 0 {
+		return 0, false
+	}
+	return info.remainingRetries, true
+}
+
+// RemainingBudget returns how much of maxElapsedTime, or of the WithDeadline
+// budget if sooner, is left. The result is false when no elapsed time limit
+// is set or ctx does not come from a running flow.
+func RemainingBudget(ctx context.Context) (time.Duration, bool) {
+	info, ok := ctx.Value(attemptKey{}).(*attemptInfo)
+	if !ok || info.maxElapsedTime <= 0 {
+		return 0, false
+	}
+	return max(info.maxElapsedTime-info.clock.Now().Sub(info.start), 0), true
+}
+
+// FlowStore returns a key/value store shared by all steps and attempts of
+// the running flow, for scratch state outside the output chain. It returns
+// nil when ctx does not come from a running flow.
+func FlowStore(ctx context.Context) *sync.Map {
+	info, ok := ctx.Value(attemptKey{}).(*attemptInfo)
+	if !ok {
+		return nil
+	}
+	return info.store
+}
+
+// StopRetrying tells the running flow not to retry: if the current attempt
+// fails, Retry gives up with its error instead of backing off. A step, or
+// code it calls, uses it when a dependency signals that it is going away.
+// The current attempt itself continues. It has no effect when ctx does not
+// come from a running flow.
+func StopRetrying(ctx context.Context) {
+	if info, ok := ctx.Value(attemptKey{}).(*attemptInfo); ok {
+		info.stop.Store(true)
+	}
+}
+
+// stepBudget returns the time left before maxElapsedTime or the deadline of
+// ctx, whichever comes first, and false if neither applies. maxElapsedTime
+// is measured on the flow's clock and the deadline on the real clock, like
+// the context that enforces it.
+func stepBudget(ctx context.Context) (time.Duration, bool) {
+	budget, ok := RemainingBudget(ctx)
+	if deadline, hasDeadline := ctx.Deadline(); hasDeadline {
+		if left := time.Until(deadline); !ok || left < budget {
+			budget, ok = max(left, 0), true
+		}
+	}
+	return budget, ok
+}
@@ -0,0 +1,49 @@
+package retryflow
+
+import "context"
+
+// Continuation is a handle to a flow paused at a checkpoint by RetryUntilCheckpoint.
+type Continuation struct {
+	steps Steps
+	opts  options
+	state flowState
+}
+
+// RetryUntilCheckpoint runs steps like Retry but stops as soon as a checkpoint
+// before the last step commits. The returned Continuation resumes the rest of
+// the flow, possibly from another goroutine or request.
+// If the flow completes without pausing, the Continuation is already done.
+func RetryUntilCheckpoint(ctx context.Context, steps Steps, opts ...Option) (*Continuation, error) {
+	o, err := buildOptions(steps, opts)
+	if err != nil {
+		return nil, err
+	}
+	c := &Continuation{steps: steps, opts: o, state: flowState{pause: true}}
+	if len(steps) == 0 {
+		return c, nil
+	}
+	if err := run(ctx, steps, &c.opts, &c.state); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Done reports whether every step of the flow has succeeded.
+func (c *Continuation) Done() bool {
+	return !c.state.paused
+}
+
+// Checkpoint returns the 1-based index of the step the flow is paused at.
+func (c *Continuation) Checkpoint() int {
+	return c.state.checkpoint
+}
+
+// Resume runs the remaining steps to completion, with the same retry
+// behaviour as Retry, starting from the paused checkpoint and its output.
+func (c *Continuation) Resume(ctx context.Context) error {
+	if c.Done() {
+		return nil
+	}
+	c.state.pause = false
+	return run(ctx, c.steps, &c.opts, &c.state)
+}
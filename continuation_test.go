@@ -0,0 +1,92 @@
+package retryflow_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Vealcoo/retryflow"
+)
+
+func TestRetryUntilCheckpointPausesAndResumes(t *testing.T) {
+	ctx := context.Background()
+	var token, profile string
+	var ran []int
+	profileAttempts := 0
+
+	steps := retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (string, error) {
+			ran = append(ran, 1)
+			return "token", nil
+		}).Do(&token).Checkpoint(),
+
+		retryflow.Chain(func(ctx context.Context, token string) (string, error) {
+			ran = append(ran, 2)
+			profileAttempts++
+			if profileAttempts < 2 {
+				return "", errors.New("fail")
+			}
+			return "profile for " + token, nil
+		}).Do(&profile),
+	)
+
+	c, err := retryflow.RetryUntilCheckpoint(ctx, steps,
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if c.Done() {
+		t.Fatal("expected flow to pause at the checkpoint")
+	}
+	if c.Checkpoint() != 1 || token != "token" || len(ran) != 1 {
+		t.Fatalf("expected pause after step 1, got checkpoint=%d token=%q ran=%v", c.Checkpoint(), token, ran)
+	}
+
+	if err := c.Resume(ctx); err != nil {
+		t.Fatalf("expected resume to succeed, got %v", err)
+	}
+	if !c.Done() {
+		t.Error("expected flow to be done after resume")
+	}
+	if profile != "profile for token" {
+		t.Errorf("unexpected profile: %q", profile)
+	}
+	if len(ran) != 3 || ran[1] != 2 || ran[2] != 2 {
+		t.Errorf("expected step 1 to run once and step 2 twice, got %v", ran)
+	}
+}
+
+func TestRetryUntilCheckpointWithoutCheckpoint(t *testing.T) {
+	ran := 0
+	c, err := retryflow.RetryUntilCheckpoint(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { ran++; return nil }),
+	))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !c.Done() || ran != 1 {
+		t.Errorf("expected completed flow, got done=%v ran=%d", c.Done(), ran)
+	}
+	if err := c.Resume(context.Background()); err != nil || ran != 1 {
+		t.Errorf("expected resume of a done flow to be a no-op, got err=%v ran=%d", err, ran)
+	}
+}
+
+func TestResumeCompensatesStepsBeforePause(t *testing.T) {
+	ctx := context.Background()
+	var compensated []any
+	c, err := retryflow.RetryUntilCheckpoint(ctx, sagaSteps(&compensated, nil), retryflow.WithCompensateAll(true))
+	if err != nil || c.Done() {
+		t.Fatalf("expected the flow to pause, got done=%v err=%v", c != nil && c.Done(), err)
+	}
+
+	if err := c.Resume(ctx); !errors.As(err, new(permanentErr)) {
+		t.Fatalf("expected the step error, got %v", err)
+	}
+	if len(compensated) != 3 || compensated[0] != 3 || compensated[1] != 2 || compensated[2] != 1 {
+		t.Errorf("expected the steps before and after the pause compensated in reverse, got %v", compensated)
+	}
+}
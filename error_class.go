@@ -37,6 +37,49 @@ func NewErrorClass(err error) ErrorClass {
 	return ErrorClass(strings.ToLower(fmt.Sprintf("%T", err)))
 }
 
+// MultiErrorPolicy decides how an error joining several errors, such as one
+// built with errors.Join, is classified.
+type MultiErrorPolicy int
+
+const (
+	// MultiErrorMostSevere classifies a joined error by its most severe member.
+	MultiErrorMostSevere MultiErrorPolicy = iota
+	// MultiErrorAnyPermanent behaves like MultiErrorMostSevere but also aborts
+	// the flow without retrying when any member is ClassPermanent.
+	MultiErrorAnyPermanent
+)
+
+// classSeverity ranks the built-in classes; other classes rank with ClassUnknown.
+var classSeverity = map[ErrorClass]int{
+	ClassPermanent: 5,
+	ClassAuth:      4,
+	ClassRateLimit: 3,
+	ClassTimeout:   2,
+	ClassTransient: 1,
+}
+
+// classify returns the class of err and whether err joins several errors.
+// Joined errors are classified member by member and reduced to the most
+// severe class; ties go to the first member. A Classify override of the
+// failed step, which may be nil, takes precedence over WithErrorClassifier.
+func (o *options) classify(err error, step *Step) (ErrorClass, bool) {
+	classifier := o.errorClassifier
+	if step != nil && step.classify != nil {
+		classifier = step.classify
+	}
+	leaves := leafErrors(err)
+	if len(leaves) == 1 {
+		return classifier(err), false
+	}
+	class := classifier(leaves[0])
+	for _, leaf := range leaves[1:] {
+		if c := classifier(leaf); classSeverity[c] > classSeverity[class] {
+			class = c
+		}
+	}
+	return class, true
+}
+
 // errorClassLimit defines a map of ErrorClass to retry limits.
 type errorClassLimit map[ErrorClass]int
 
@@ -0,0 +1,220 @@
+package retryflow_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Vealcoo/retryflow"
+)
+
+var (
+	errTransient = errors.New("transient")
+	errPermanent = errors.New("permanent")
+)
+
+func classifyJoined(err error) retryflow.ErrorClass {
+	switch {
+	case errors.Is(err, errPermanent):
+		return retryflow.ClassPermanent
+	case errors.Is(err, errTransient):
+		return retryflow.ClassTransient
+	default:
+		return retryflow.ClassUnknown
+	}
+}
+
+func TestJoinedErrorMostSevereClassGoverns(t *testing.T) {
+	attempts := 0
+	var classes []retryflow.ErrorClass
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			attempts++
+			return errors.Join(errTransient, errPermanent)
+		}),
+	),
+		retryflow.WithErrorClassifier(func(err error) retryflow.ErrorClass {
+			c := classifyJoined(err)
+			classes = append(classes, c)
+			return c
+		}),
+		retryflow.WithPerErrorLimits(retryflow.NewErrorClassLimit().
+			AddLimit(retryflow.ClassTransient, 5).
+			AddLimit(retryflow.ClassPermanent, 0)),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if !errors.Is(err, errPermanent) {
+		t.Fatalf("expected joined error, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("expected the permanent class to stop retries after 1 attempt, got %d", attempts)
+	}
+	if len(classes) != 2 {
+		t.Errorf("expected each joined error to be classified, got %v", classes)
+	}
+}
+
+func TestJoinedErrorAnyPermanentAborts(t *testing.T) {
+	attempts := 0
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			attempts++
+			return errors.Join(errTransient, errPermanent)
+		}),
+	),
+		retryflow.WithErrorClassifier(classifyJoined),
+		retryflow.WithMultiErrorPolicy(retryflow.MultiErrorAnyPermanent),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if attempts != 1 {
+		t.Errorf("expected abort after 1 attempt, got %d", attempts)
+	}
+}
+
+func TestMaxDistinctErrorClasses(t *testing.T) {
+	classes := []retryflow.ErrorClass{
+		retryflow.ClassAuth,
+		retryflow.ClassTimeout,
+		retryflow.ClassAuth,
+		retryflow.ClassRateLimit,
+		retryflow.ClassUnknown,
+		retryflow.ClassTransient,
+	}
+	attempts := 0
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			attempts++
+			return errTransient
+		}),
+	),
+		retryflow.WithErrorClassifier(func(err error) retryflow.ErrorClass { return classes[attempts-1] }),
+		retryflow.WithMaxDistinctErrorClasses(3),
+		retryflow.WithMaxRetries(10),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if !errors.Is(err, errTransient) {
+		t.Fatalf("expected errTransient, got %v", err)
+	}
+	if attempts != 5 {
+		t.Errorf("expected to give up on the fourth distinct class at attempt 5, got %d attempts", attempts)
+	}
+}
+
+func TestStepClassifyOverride(t *testing.T) {
+	errTimeout := errors.New("timeout")
+	var classes []retryflow.ErrorClass
+	attempts := 0
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			attempts++
+			if attempts%2 == 1 {
+				return errTimeout
+			}
+			return nil
+		}).Classify(func(err error) retryflow.ErrorClass { return retryflow.ClassTransient }),
+		retryflow.Exec(func(ctx context.Context) error { return errTimeout }),
+	),
+		retryflow.WithErrorClassifier(func(err error) retryflow.ErrorClass { return retryflow.ClassTimeout }),
+		retryflow.WithOnRetry(func(attempt int, err error) {
+			var ae *retryflow.AttemptError
+			if errors.As(err, &ae) && ae.Step == 1 {
+				classes = append(classes, retryflow.ClassTransient)
+			} else {
+				classes = append(classes, retryflow.ClassTimeout)
+			}
+		}),
+		retryflow.WithPerErrorLimits(retryflow.NewErrorClassLimit().AddLimit(retryflow.ClassTimeout, 2)),
+		retryflow.WithMaxRetries(10),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if !errors.Is(err, errTimeout) {
+		t.Fatalf("expected errTimeout, got %v", err)
+	}
+	// Step 1 failures are transient and do not count against the timeout limit
+	want := []retryflow.ErrorClass{
+		retryflow.ClassTransient, retryflow.ClassTimeout,
+		retryflow.ClassTransient, retryflow.ClassTimeout,
+		retryflow.ClassTransient,
+	}
+	if !slices.Equal(classes, want) {
+		t.Errorf("expected retries %v, got %v", want, classes)
+	}
+}
+
+func TestRestartClasses(t *testing.T) {
+	errAuth := errors.New("token expired")
+	classify := func(err error) retryflow.ErrorClass {
+		if errors.Is(err, errAuth) {
+			return retryflow.ClassAuth
+		}
+		return retryflow.ClassTransient
+	}
+	failures := []error{errTransient, errAuth, errTransient, errAuth}
+	logins, calls := 0, 0
+	var tokens []int
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (int, error) {
+			logins++
+			return logins, nil
+		}).Checkpoint(),
+		retryflow.Chain(func(ctx context.Context, token int) (any, error) {
+			tokens = append(tokens, token)
+			calls++
+			if calls <= len(failures) {
+				return nil, failures[calls-1]
+			}
+			return nil, nil
+		}),
+	),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithMaxRetries(10),
+		retryflow.WithErrorClassifier(classify),
+		retryflow.WithRestartClasses(retryflow.ClassAuth),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// Transient errors resume with the same token, auth errors log in again
+	if want := []int{1, 1, 2, 2, 3}; !slices.Equal(tokens, want) {
+		t.Errorf("expected tokens %v, got %v", want, tokens)
+	}
+
+	// Restarted failures still count towards their per-error limit
+	logins, calls, tokens = 0, 0, nil
+	failures = []error{errAuth, errAuth, errAuth}
+	err = retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (int, error) {
+			logins++
+			return logins, nil
+		}).Checkpoint(),
+		retryflow.Chain(func(ctx context.Context, token int) (any, error) {
+			calls++
+			return nil, failures[min(calls, len(failures))-1]
+		}),
+	),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithMaxRetries(10),
+		retryflow.WithErrorClassifier(classify),
+		retryflow.WithRestartClasses(retryflow.ClassAuth),
+		retryflow.WithPerErrorLimits(map[retryflow.ErrorClass]int{retryflow.ClassAuth: 1}),
+	)
+	if !errors.Is(err, errAuth) || logins != 2 {
+		t.Errorf("expected to give up on the second auth error after 2 logins, got %v after %d", err, logins)
+	}
+}
@@ -0,0 +1,45 @@
+package retryflow
+
+import "encoding/json"
+
+// flowExport is the JSON form of a flow produced by Steps.Export.
+type flowExport struct {
+	Steps []stepExport `json:"steps"`
+}
+
+type stepExport struct {
+	Index       int               `json:"index"`
+	Name        string            `json:"name,omitempty"`
+	Label       string            `json:"label,omitempty"`
+	Checkpoint  bool              `json:"checkpoint,omitempty"`
+	Optional    bool              `json:"optional,omitempty"`
+	Conditional bool              `json:"conditional,omitempty"`
+	RetryFrom   string            `json:"retryFrom,omitempty"`
+	Timeout     string            `json:"timeout,omitempty"`
+	Meta        map[string]string `json:"meta,omitempty"`
+}
+
+// Export describes the static structure of the flow as JSON, for tools that
+// visualize or audit flows: for each step its 1-based index, name, label,
+// metadata and flags such as checkpoint, optional and conditional (When).
+// Step functions are not included.
+func (s Steps) Export() ([]byte, error) {
+	out := flowExport{Steps: make([]stepExport, len(s))}
+	for i, step := range s {
+		e := stepExport{
+			Index:       i + 1,
+			Name:        step.name,
+			Label:       step.label,
+			Checkpoint:  step.checkpoint,
+			Optional:    step.optional,
+			Conditional: step.when != nil,
+			RetryFrom:   step.retryFrom,
+			Meta:        step.meta,
+		}
+		if step.timeout > 0 {
+			e.Timeout = step.timeout.String()
+		}
+		out.Steps[i] = e
+	}
+	return json.Marshal(out)
+}
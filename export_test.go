@@ -0,0 +1,53 @@
+package retryflow_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Vealcoo/retryflow"
+)
+
+func TestStepsExport(t *testing.T) {
+	noop := func(ctx context.Context) error { return nil }
+	steps := retryflow.Seq(
+		retryflow.Exec(noop).Name("reserve").Label("reserve").Meta("owner", "inventory"),
+		retryflow.Exec(noop).Name("charge").Checkpoint().Timeout(2*time.Second),
+		retryflow.Exec(noop).Name("notify").Optional().RetryFrom("reserve"),
+	)
+
+	data, err := steps.Export()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var got struct {
+		Steps []struct {
+			Index      int               `json:"index"`
+			Name       string            `json:"name"`
+			Label      string            `json:"label"`
+			Checkpoint bool              `json:"checkpoint"`
+			Optional   bool              `json:"optional"`
+			RetryFrom  string            `json:"retryFrom"`
+			Timeout    string            `json:"timeout"`
+			Meta       map[string]string `json:"meta"`
+		} `json:"steps"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("invalid JSON %s: %v", data, err)
+	}
+	if len(got.Steps) != 3 {
+		t.Fatalf("expected 3 steps, got %s", data)
+	}
+	s1, s2, s3 := got.Steps[0], got.Steps[1], got.Steps[2]
+	if s1.Index != 1 || s1.Name != "reserve" || s1.Label != "reserve" || s1.Meta["owner"] != "inventory" {
+		t.Errorf("unexpected first step: %+v", s1)
+	}
+	if s2.Name != "charge" || !s2.Checkpoint || s2.Timeout != "2s" {
+		t.Errorf("unexpected second step: %+v", s2)
+	}
+	if s3.Index != 3 || s3.Checkpoint || !s3.Optional || s3.RetryFrom != "reserve" {
+		t.Errorf("unexpected third step: %+v", s3)
+	}
+}
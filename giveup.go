@@ -0,0 +1,80 @@
+package retryflow
+
+import "fmt"
+
+// GiveUpReason tells why a flow stopped retrying, as reported to the
+// WithOnGiveUp hook.
+type GiveUpReason int
+
+const (
+	// GiveUpNonRetryable means the error was classified as not retryable.
+	GiveUpNonRetryable GiveUpReason = iota
+	// GiveUpMaxRetries means WithMaxRetries was reached.
+	GiveUpMaxRetries
+	// GiveUpMaxElapsedTime means WithMaxElapsedTime was exceeded, either
+	// after a failure or while a step or backoff sleep was cut short by it.
+	GiveUpMaxElapsedTime
+	// GiveUpErrorLimit means a WithPerErrorLimits or
+	// WithMaxDistinctErrorClasses limit was exceeded.
+	GiveUpErrorLimit
+	// GiveUpErrorRate means a ForEach batch exceeded WithErrorRateThreshold.
+	GiveUpErrorRate
+	// GiveUpMaxIterations means WithMaxIterations was reached.
+	GiveUpMaxIterations
+	// GiveUpSchedule means the WithScheduleGuard guard denied the retry.
+	GiveUpSchedule
+	// GiveUpStopped means a step called StopRetrying.
+	GiveUpStopped
+	// GiveUpCircuitOpen means the WithCircuitBreaker breaker rejected the
+	// next attempt. The error is ErrCircuitOpen.
+	GiveUpCircuitOpen
+	// GiveUpBudget means the WithRetryBudget budget was exhausted.
+	GiveUpBudget
+	// GiveUpDeadline means the next backoff sleep would outlast the context
+	// deadline, so the flow returned the last error instead of sleeping.
+	GiveUpDeadline
+	// GiveUpCanceled means the context ended, or the rate limiter failed,
+	// while the flow was retrying, after at least one failed attempt.
+	GiveUpCanceled
+	// GiveUpStepExhausted means a step reached its MaxAttempts. The error
+	// is a *StepExhaustedError.
+	GiveUpStepExhausted
+	// GiveUpStuck means the WithMaxSameOutput step kept producing the same
+	// output. The error is a *StuckError.
+	GiveUpStuck
+)
+
+func (r GiveUpReason) String() string {
+	switch r {
+	case GiveUpNonRetryable:
+		return "non-retryable"
+	case GiveUpMaxRetries:
+		return "max retries"
+	case GiveUpMaxElapsedTime:
+		return "max elapsed time"
+	case GiveUpErrorLimit:
+		return "error limit"
+	case GiveUpErrorRate:
+		return "error rate"
+	case GiveUpMaxIterations:
+		return "max iterations"
+	case GiveUpSchedule:
+		return "schedule"
+	case GiveUpStopped:
+		return "stopped"
+	case GiveUpCircuitOpen:
+		return "circuit open"
+	case GiveUpBudget:
+		return "budget"
+	case GiveUpDeadline:
+		return "deadline"
+	case GiveUpCanceled:
+		return "canceled"
+	case GiveUpStepExhausted:
+		return "step exhausted"
+	case GiveUpStuck:
+		return "stuck"
+	default:
+		return fmt.Sprintf("GiveUpReason(%d)", int(r))
+	}
+}
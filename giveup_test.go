@@ -0,0 +1,276 @@
+package retryflow_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Vealcoo/retryflow"
+	"github.com/Vealcoo/retryflow/retryflowtest"
+)
+
+type permanentErr struct{}
+
+func (permanentErr) Error() string   { return "permanent" }
+func (permanentErr) Permanent() bool { return true }
+
+// brokenLimiter lets the first attempt through and fails afterwards.
+type brokenLimiter struct{ waits int }
+
+func (l *brokenLimiter) Wait(ctx context.Context) error {
+	if l.waits++; l.waits > 1 {
+		return errors.New("limiter broke")
+	}
+	return nil
+}
+
+func TestOnGiveUpReasons(t *testing.T) {
+	errFail := errors.New("fail")
+	tests := []struct {
+		name   string
+		err    error
+		opts   []retryflow.Option
+		reason retryflow.GiveUpReason
+	}{
+		{
+			name:   "non-retryable",
+			err:    permanentErr{},
+			reason: retryflow.GiveUpNonRetryable,
+		},
+		{
+			name:   "max retries",
+			err:    errFail,
+			opts:   []retryflow.Option{retryflow.WithMaxRetries(2)},
+			reason: retryflow.GiveUpMaxRetries,
+		},
+		{
+			name: "max elapsed time",
+			err:  errFail,
+			opts: []retryflow.Option{
+				retryflow.WithClock(retryflowtest.NewClock(time.Now())),
+				retryflow.WithMaxRetries(-1),
+				retryflow.WithMaxElapsedTime(10 * time.Millisecond),
+			},
+			reason: retryflow.GiveUpMaxElapsedTime,
+		},
+		{
+			name: "per-error limit",
+			err:  errFail,
+			opts: []retryflow.Option{
+				retryflow.WithErrorClassifier(func(error) retryflow.ErrorClass { return retryflow.ClassTransient }),
+				retryflow.WithPerErrorLimits(map[retryflow.ErrorClass]int{retryflow.ClassTransient: 1}),
+			},
+			reason: retryflow.GiveUpErrorLimit,
+		},
+		{
+			name:   "limiter error",
+			err:    errFail,
+			opts:   []retryflow.Option{retryflow.WithRateLimiter(&brokenLimiter{})},
+			reason: retryflow.GiveUpCanceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			var gotErr error
+			var gotReason retryflow.GiveUpReason
+			opts := append([]retryflow.Option{
+				retryflow.WithInitialBackoff(time.Millisecond),
+				retryflow.WithJitter(0),
+				retryflow.WithOnGiveUp(func(attempt int, err error, reason retryflow.GiveUpReason) {
+					calls++
+					gotErr, gotReason = err, reason
+				}),
+			}, tt.opts...)
+
+			err := retryflow.Retry(context.Background(), retryflow.Seq(
+				retryflow.Exec(func(ctx context.Context) error { return tt.err }),
+			), opts...)
+
+			if calls != 1 {
+				t.Fatalf("expected the hook to fire once, got %d", calls)
+			}
+			if gotReason != tt.reason {
+				t.Errorf("expected reason %v, got %v", tt.reason, gotReason)
+			}
+			var attemptErr *retryflow.AttemptError
+			if gotErr != err || !errors.As(err, &attemptErr) {
+				t.Errorf("expected the returned *AttemptError, got %v (returned %v)", gotErr, err)
+			}
+		})
+	}
+}
+
+func TestOnGiveUpCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var reasons []retryflow.GiveUpReason
+
+	err := retryflow.Retry(ctx, retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return errors.New("fail") }),
+	),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithOnRetry(func(attempt int, err error) { cancel() }),
+		retryflow.WithOnGiveUp(func(attempt int, err error, reason retryflow.GiveUpReason) {
+			reasons = append(reasons, reason)
+		}),
+	)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(reasons) != 1 || reasons[0] != retryflow.GiveUpCanceled {
+		t.Errorf("expected a single GiveUpCanceled, got %v", reasons)
+	}
+}
+
+func TestOnGiveUpCheckpointCooldownCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var reasons []retryflow.GiveUpReason
+	runs := 0
+
+	err := retryflow.Retry(ctx, retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			if runs++; runs == 1 {
+				return errors.New("fail")
+			}
+			return nil
+		}).Checkpoint(),
+		retryflow.Exec(func(ctx context.Context) error { return nil }),
+	),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithCheckpointCooldown(time.Hour),
+		retryflow.WithOnCheckpoint(func(int, any) { cancel() }),
+		retryflow.WithOnGiveUp(func(attempt int, err error, reason retryflow.GiveUpReason) {
+			reasons = append(reasons, reason)
+		}),
+	)
+	var attemptErr *retryflow.AttemptError
+	if !errors.Is(err, context.Canceled) || !errors.As(err, &attemptErr) {
+		t.Fatalf("expected context.Canceled with the last *AttemptError, got %v", err)
+	}
+	if len(reasons) != 1 || reasons[0] != retryflow.GiveUpCanceled {
+		t.Errorf("expected a single GiveUpCanceled, got %v", reasons)
+	}
+}
+
+func TestOnGiveUpNotFiredWithoutFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fired := false
+
+	err := retryflow.Retry(ctx, retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return nil }),
+	),
+		retryflow.WithOnGiveUp(func(int, error, retryflow.GiveUpReason) { fired = true }),
+	)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if fired {
+		t.Error("expected no give-up for a flow canceled before any failure")
+	}
+}
+
+func TestMaxElapsedTimeCancelsSlowStep(t *testing.T) {
+	var reasons []retryflow.GiveUpReason
+	start := time.Now()
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(5 * time.Second):
+				return nil
+			}
+		}),
+	),
+		retryflow.WithMaxElapsedTime(50*time.Millisecond),
+		retryflow.WithOnGiveUp(func(attempt int, err error, reason retryflow.GiveUpReason) {
+			reasons = append(reasons, reason)
+		}),
+	)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the step to be cancelled once the budget elapsed, took %v", elapsed)
+	}
+	if len(reasons) != 1 || reasons[0] != retryflow.GiveUpMaxElapsedTime {
+		t.Errorf("expected a single GiveUpMaxElapsedTime, got %v", reasons)
+	}
+}
+
+func TestMaxElapsedTimeCutsBackoffSleep(t *testing.T) {
+	errFail := errors.New("fail")
+	var reasons []retryflow.GiveUpReason
+	start := time.Now()
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return errFail }),
+	),
+		retryflow.WithMaxElapsedTime(50*time.Millisecond),
+		retryflow.WithInitialBackoff(5*time.Second),
+		retryflow.WithJitter(0),
+		retryflow.WithOnGiveUp(func(attempt int, err error, reason retryflow.GiveUpReason) {
+			reasons = append(reasons, reason)
+		}),
+	)
+	if !errors.Is(err, errFail) {
+		t.Fatalf("expected the last step error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the sleep to end with the budget, took %v", elapsed)
+	}
+	if len(reasons) != 1 || reasons[0] != retryflow.GiveUpMaxElapsedTime {
+		t.Errorf("expected a single GiveUpMaxElapsedTime, got %v", reasons)
+	}
+}
+
+func TestMaxElapsedTimeWithClock(t *testing.T) {
+	clock := retryflowtest.NewClock(time.Now())
+	var reasons []retryflow.GiveUpReason
+	opts := []retryflow.Option{
+		retryflow.WithClock(clock),
+		retryflow.WithMaxElapsedTime(20 * time.Millisecond),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithOnGiveUp(func(attempt int, err error, reason retryflow.GiveUpReason) {
+			reasons = append(reasons, reason)
+		}),
+	}
+
+	// Wall time past the budget does not cancel a step under a fake clock
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(50 * time.Millisecond):
+				return nil
+			}
+		}),
+	), opts...)
+	if err != nil {
+		t.Fatalf("expected the step to finish, got %v", err)
+	}
+
+	// Fake time past the budget gives up after the failure
+	errFail := errors.New("fail")
+	err = retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			clock.Advance(30 * time.Millisecond)
+			return errFail
+		}),
+	), opts...)
+	if !errors.Is(err, errFail) {
+		t.Fatalf("expected the step error, got %v", err)
+	}
+	if len(reasons) != 1 || reasons[0] != retryflow.GiveUpMaxElapsedTime {
+		t.Errorf("expected a single GiveUpMaxElapsedTime, got %v", reasons)
+	}
+}
@@ -2,4 +2,7 @@ module github.com/Vealcoo/retryflow
 
 go 1.24.1
 
-require golang.org/x/time v0.14.0
+require (
+	golang.org/x/sync v0.19.0
+	golang.org/x/time v0.14.0
+)
@@ -0,0 +1,29 @@
+package retryflow
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+)
+
+// IdempotencyKeyFromContext returns the idempotency key of the running flow,
+// set with WithIdempotencyKey or generated as a random UUID when the flow
+// starts. It is the same in every attempt, also after checkpoints, so that
+// steps can pass it to non-idempotent operations they retry. It returns ""
+// when ctx does not come from a running flow.
+func IdempotencyKeyFromContext(ctx context.Context) string {
+	info, ok := ctx.Value(attemptKey{}).(*attemptInfo)
+	if !ok {
+		return ""
+	}
+	return info.idempotencyKey
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:]) // never fails
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,102 @@
+package retryflow
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// JitterMode selects how randomness is applied to the computed backoff.
+type JitterMode int
+
+const (
+	// JitterNone sleeps exactly the computed backoff.
+	JitterNone JitterMode = iota
+	// JitterFull sleeps a random duration between 0 and the computed backoff.
+	JitterFull
+	// JitterEqual sleeps half the computed backoff plus a random duration
+	// up to the other half.
+	JitterEqual
+	// JitterAdditive adds a random offset within ±WithJitter (or
+	// ±WithJitterFraction of the backoff) to the computed backoff. It is the
+	// default.
+	JitterAdditive
+)
+
+func (m JitterMode) String() string {
+	switch m {
+	case JitterNone:
+		return "none"
+	case JitterFull:
+		return "full"
+	case JitterEqual:
+		return "equal"
+	case JitterAdditive:
+		return "additive"
+	default:
+		return fmt.Sprintf("JitterMode(%d)", int(m))
+	}
+}
+
+// jittered returns the sleep for the computed backoff next after a failure
+// of class key caused by step, which is nil if no step failed. streak counts
+// the consecutive failures of class key, for WithAdaptiveJitter.
+func (o *options) jittered(next time.Duration, key ErrorClass, step *Step, streak int) time.Duration {
+	if o.jitterClasses != nil && !o.jitterClasses[key] {
+		return next
+	}
+	switch o.jitterMode {
+	case JitterFull:
+		if next <= 0 {
+			return next
+		}
+		return time.Duration(o.int63n(int64(next)))
+	case JitterEqual:
+		half := next / 2
+		if half <= 0 {
+			return next
+		}
+		return next - half + time.Duration(o.int63n(int64(half)))
+	case JitterAdditive:
+		jitter := o.jitter
+		if o.jitterFraction > 0 {
+			jitter = time.Duration(float64(next) * o.jitterFraction)
+		}
+		if step != nil && step.jitter != nil {
+			jitter = *step.jitter
+		}
+		if o.adaptiveJitter && streak > 1 {
+			jitter = max(jitter, min(jitter*time.Duration(streak), next))
+		}
+		if jitter <= 0 {
+			return next
+		}
+		sleep := next + time.Duration(o.int63n(int64(jitter*2))) - jitter
+		return max(sleep, 10*time.Millisecond)
+	default:
+		return next
+	}
+}
+
+// lockedRand makes a *rand.Rand safe for concurrent use.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (l *lockedRand) Int63n(n int64) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Int63n(n)
+}
+
+// int63n returns a random number in [0, n) from the flow's random source,
+// creating a source seeded from the time on first use if WithRand is not
+// set, so that flows do not contend on the global source.
+func (o *options) int63n(n int64) int64 {
+	if o.rand == nil {
+		o.rand = &lockedRand{r: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	}
+	return o.rand.Int63n(n)
+}
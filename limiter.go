@@ -0,0 +1,80 @@
+package retryflow
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// Limiter paces attempts. *rate.Limiter and *AdaptiveLimiter implement it.
+type Limiter interface {
+	Wait(ctx context.Context) error
+}
+
+// ErrLimiterQueueFull is returned when WithMaxQueuedRetries callers are
+// already waiting on the flow's rate limiter.
+var ErrLimiterQueueFull = errors.New("rate limiter queue full")
+
+// waitLimiter waits on the flow's rate limiter, failing fast with
+// ErrLimiterQueueFull when maxQueued callers already wait on it.
+func (o *options) waitLimiter(ctx context.Context) error {
+	if o.maxQueued > 0 && o.queued != nil {
+		defer o.queued.Add(-1)
+		if o.queued.Add(1) > int64(o.maxQueued) {
+			return ErrLimiterQueueFull
+		}
+	}
+	return o.rateLimiter.Wait(ctx)
+}
+
+// throttler is implemented by limiters that adapt to rate limit errors.
+type throttler interface {
+	Throttle()
+	Restore()
+}
+
+// AdaptiveLimiter is a rate limiter that slows down when attempts fail with
+// ClassRateLimit and returns to its base rate once steps succeed again.
+type AdaptiveLimiter struct {
+	mu      sync.Mutex
+	limiter *rate.Limiter
+	base    rate.Limit
+	min     rate.Limit
+}
+
+// NewAdaptiveLimiter creates an AdaptiveLimiter that allows r events per
+// second with the given burst. Each rate limit error halves the rate, but
+// never below min.
+func NewAdaptiveLimiter(r rate.Limit, burst int, min rate.Limit) *AdaptiveLimiter {
+	return &AdaptiveLimiter{
+		limiter: rate.NewLimiter(r, burst),
+		base:    r,
+		min:     min,
+	}
+}
+
+// Wait blocks until the limiter permits an event or ctx is done.
+func (a *AdaptiveLimiter) Wait(ctx context.Context) error {
+	return a.limiter.Wait(ctx)
+}
+
+// Limit returns the current rate.
+func (a *AdaptiveLimiter) Limit() rate.Limit {
+	return a.limiter.Limit()
+}
+
+// Throttle halves the current rate, down to the configured minimum.
+func (a *AdaptiveLimiter) Throttle() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.limiter.SetLimit(max(a.limiter.Limit()/2, a.min))
+}
+
+// Restore resets the rate to its base value.
+func (a *AdaptiveLimiter) Restore() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.limiter.SetLimit(a.base)
+}
@@ -0,0 +1,143 @@
+package retryflow_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Vealcoo/retryflow"
+	"golang.org/x/time/rate"
+)
+
+func TestAdaptiveLimiterWidensSpacing(t *testing.T) {
+	ctx := context.Background()
+	limiter := retryflow.NewAdaptiveLimiter(rate.Every(10*time.Millisecond), 1, rate.Every(time.Second))
+	var starts []time.Time
+
+	steps := retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			starts = append(starts, time.Now())
+			return errors.New("429 too many requests")
+		}),
+	)
+
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithRateLimiter(limiter),
+		retryflow.WithMaxRetries(5),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithBackoffStrategy(retryflow.ConstantBackoff),
+		retryflow.WithJitter(0),
+		retryflow.WithErrorClassifier(func(err error) retryflow.ErrorClass { return retryflow.ClassRateLimit }),
+	)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if len(starts) != 5 {
+		t.Fatalf("expected 5 attempts, got %d", len(starts))
+	}
+	first := starts[2].Sub(starts[1])
+	last := starts[4].Sub(starts[3])
+	if last < 2*first {
+		t.Errorf("expected spacing to widen, first gap %v, last gap %v", first, last)
+	}
+	if limiter.Limit() >= rate.Every(10*time.Millisecond) {
+		t.Errorf("expected throttled limit, got %v", limiter.Limit())
+	}
+}
+
+func TestAdaptiveLimiterRestoresOnSuccess(t *testing.T) {
+	ctx := context.Background()
+	base := rate.Every(time.Millisecond)
+	limiter := retryflow.NewAdaptiveLimiter(base, 1, rate.Every(time.Second))
+	attempts := 0
+
+	steps := retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			attempts++
+			if attempts < 3 {
+				return errors.New("429 too many requests")
+			}
+			return nil
+		}),
+	)
+
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithRateLimiter(limiter),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithErrorClassifier(func(err error) retryflow.ErrorClass { return retryflow.ClassRateLimit }),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if limiter.Limit() != base {
+		t.Errorf("expected limit restored to %v, got %v", base, limiter.Limit())
+	}
+}
+
+// gateLimiter blocks every Wait until release is closed.
+type gateLimiter struct {
+	waiting chan struct{}
+	release chan struct{}
+}
+
+func (l *gateLimiter) Wait(ctx context.Context) error {
+	l.waiting <- struct{}{}
+	select {
+	case <-l.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestMaxQueuedRetries(t *testing.T) {
+	ctx := context.Background()
+	limiter := &gateLimiter{waiting: make(chan struct{}, 10), release: make(chan struct{})}
+	queue := retryflow.WithMaxQueuedRetries(2)
+	flow := func() error {
+		return retryflow.Retry(ctx, retryflow.Seq(
+			retryflow.Exec(func(ctx context.Context) error { return nil }),
+		), retryflow.WithRateLimiter(limiter), queue)
+	}
+
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() { errs <- flow() }()
+	}
+	<-limiter.waiting
+	<-limiter.waiting
+
+	if err := flow(); !errors.Is(err, retryflow.ErrLimiterQueueFull) {
+		t.Errorf("expected ErrLimiterQueueFull beyond 2 queued flows, got %v", err)
+	}
+
+	close(limiter.release)
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Errorf("expected queued flow to succeed, got %v", err)
+		}
+	}
+	if err := flow(); err != nil {
+		t.Errorf("expected flow to succeed once the queue drained, got %v", err)
+	}
+}
+
+// limiterFunc is a Limiter that is not comparable.
+type limiterFunc func(ctx context.Context) error
+
+func (f limiterFunc) Wait(ctx context.Context) error { return f(ctx) }
+
+func TestMaxQueuedRetriesUncomparableLimiter(t *testing.T) {
+	waits := 0
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return nil }),
+	),
+		retryflow.WithRateLimiter(limiterFunc(func(context.Context) error { waits++; return nil })),
+		retryflow.WithMaxQueuedRetries(1),
+	)
+	if err != nil || waits != 1 {
+		t.Errorf("expected one wait and no error, got %d and %v", waits, err)
+	}
+}
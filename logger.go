@@ -0,0 +1,47 @@
+package retryflow
+
+import (
+	"errors"
+	"log/slog"
+)
+
+// Logger receives structured log entries from a flow. kv holds alternating
+// keys and values, as accepted by log/slog.
+type Logger interface {
+	Debug(msg string, kv ...any)
+	Info(msg string, kv ...any)
+	Warn(msg string, kv ...any)
+}
+
+// NopLogger is a Logger discarding every entry. It is the default.
+type NopLogger struct{}
+
+func (NopLogger) Debug(msg string, kv ...any) {}
+func (NopLogger) Info(msg string, kv ...any)  {}
+func (NopLogger) Warn(msg string, kv ...any)  {}
+
+// NewSlogLogger returns a Logger writing to l, or to slog.Default if l is
+// nil.
+func NewSlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return slogLogger{l}
+}
+
+type slogLogger struct{ l *slog.Logger }
+
+func (s slogLogger) Debug(msg string, kv ...any) { s.l.Debug(msg, kv...) }
+func (s slogLogger) Info(msg string, kv ...any)  { s.l.Info(msg, kv...) }
+func (s slogLogger) Warn(msg string, kv ...any)  { s.l.Warn(msg, kv...) }
+
+// logFields returns the fields identifying a failed attempt: the flow name,
+// attempt, failed step and the error class the flow decided for err.
+func (o *options) logFields(attempt int, err error, class ErrorClass) []any {
+	step := 0
+	var attemptErr *AttemptError
+	if errors.As(err, &attemptErr) {
+		step = attemptErr.Step
+	}
+	return []any{"flow", o.flowName, "attempt", attempt, "step", step, "class", class, "error", err}
+}
@@ -0,0 +1,187 @@
+package retryflow_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Vealcoo/retryflow"
+)
+
+type logEntry struct {
+	level, msg string
+	fields     map[string]any
+}
+
+// recordingLogger is a retryflow.Logger keeping every entry.
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (l *recordingLogger) record(level, msg string, kv []any) {
+	fields := make(map[string]any, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		fields[kv[i].(string)] = kv[i+1]
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, logEntry{level, msg, fields})
+}
+
+func (l *recordingLogger) Debug(msg string, kv ...any) { l.record("debug", msg, kv) }
+func (l *recordingLogger) Info(msg string, kv ...any)  { l.record("info", msg, kv) }
+func (l *recordingLogger) Warn(msg string, kv ...any)  { l.record("warn", msg, kv) }
+
+func (l *recordingLogger) find(msg string) []logEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var found []logEntry
+	for _, e := range l.entries {
+		if e.msg == msg {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+func TestLoggerStepClass(t *testing.T) {
+	logger := &recordingLogger{}
+	var ends []string
+	attempts := 0
+	_ = retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			if attempts++; attempts == 2 {
+				retryflow.StopRetrying(ctx) // gives up before the retry checks
+			}
+			return errors.New("fail")
+		}).Classify(func(error) retryflow.ErrorClass { return retryflow.ClassRateLimit }),
+	),
+		retryflow.WithLogger(logger),
+		retryflow.WithObserver(&recordingObserver{ends: &ends}),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+
+	entries := append(logger.find("retrying"), logger.find("giving up")...)
+	if len(entries) != 2 {
+		t.Fatalf("expected a retry and a give-up entry, got %v", entries)
+	}
+	for _, e := range entries {
+		if e.fields["class"] != retryflow.ClassRateLimit {
+			t.Errorf("expected the step's class on %q, got %v", e.msg, e.fields["class"])
+		}
+	}
+	for _, end := range ends {
+		if strings.HasPrefix(end, "attempt ") && !strings.Contains(end, string(retryflow.ClassRateLimit)) {
+			t.Errorf("expected the observer to see the step's class, got %q", end)
+		}
+	}
+}
+
+func TestLoggerFields(t *testing.T) {
+	logger := &recordingLogger{}
+	errFail := errors.New("fail")
+
+	_ = retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return nil }),
+		retryflow.Exec(func(ctx context.Context) error { return errFail }),
+	),
+		retryflow.WithLogger(logger),
+		retryflow.WithFlowName("checkout"),
+		retryflow.WithMaxRetries(2),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithErrorClassifier(func(error) retryflow.ErrorClass { return retryflow.ClassTransient }),
+	)
+
+	check := func(e logEntry, attempt int) {
+		t.Helper()
+		f := e.fields
+		if f["flow"] != "checkout" || f["attempt"] != attempt || f["step"] != 2 || f["class"] != retryflow.ClassTransient {
+			t.Errorf("unexpected fields on %q: %v", e.msg, f)
+		}
+		if err, _ := f["error"].(error); !errors.Is(err, errFail) {
+			t.Errorf("expected error field wrapping errFail on %q, got %v", e.msg, f["error"])
+		}
+	}
+
+	retries := logger.find("retrying")
+	if len(retries) != 1 {
+		t.Fatalf("expected 1 retry entry, got %v", retries)
+	}
+	check(retries[0], 1)
+	if retries[0].level != "info" || retries[0].fields["backoff"] != time.Millisecond {
+		t.Errorf("unexpected retry entry: %+v", retries[0])
+	}
+
+	giveUps := logger.find("giving up")
+	if len(giveUps) != 1 {
+		t.Fatalf("expected 1 give-up entry, got %v", giveUps)
+	}
+	check(giveUps[0], 2)
+	if giveUps[0].level != "warn" || giveUps[0].fields["reason"] != "max retries" {
+		t.Errorf("unexpected give-up entry: %+v", giveUps[0])
+	}
+}
+
+func TestLoggerEvents(t *testing.T) {
+	logger := &recordingLogger{}
+	failed := false
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return nil }).Checkpoint(),
+		retryflow.Exec(func(ctx context.Context) error {
+			if !failed {
+				failed = true
+				return errors.New("fail")
+			}
+			return nil
+		}),
+	),
+		retryflow.WithLogger(logger),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	want := []string{
+		"debug attempt started step=1",
+		"debug step succeeded step=1",
+		"info checkpoint reached step=1",
+		"info retrying step=2",
+		"debug attempt started step=2",
+		"debug step succeeded step=2",
+	}
+	var got []string
+	for _, e := range logger.entries {
+		got = append(got, fmt.Sprintf("%s %s step=%v", e.level, e.msg, e.fields["step"]))
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected entries\n%v\ngot\n%v", want, got)
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := retryflow.NewSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return nil }),
+	), retryflow.WithLogger(logger), retryflow.WithFlowName("checkout"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, `level=DEBUG msg="step succeeded" flow=checkout attempt=1 step=1`) {
+		t.Errorf("unexpected slog output: %s", out)
+	}
+}
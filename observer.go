@@ -0,0 +1,77 @@
+package retryflow
+
+import (
+	"context"
+	"time"
+)
+
+// Observer follows the flow, its attempts and their steps as nested
+// operations, for tracing integrations such as retryflowotel. Each Start
+// method returns the context the operation runs with, which is also passed
+// to the matching End method, so an Observer can keep its state, such as a
+// span, in the context. Set it with WithObserver.
+type Observer interface {
+	// StartFlow is called when Retry starts, with the WithFlowName name.
+	StartFlow(ctx context.Context, name string) context.Context
+	// EndFlow is called with the error Retry returns.
+	EndFlow(ctx context.Context, err error)
+	// StartAttempt is called when an attempt starts.
+	StartAttempt(ctx context.Context, attempt int) context.Context
+	// EndAttempt is called when an attempt ends, before the backoff sleep
+	// that follows a failure. err is nil if the attempt succeeded; class
+	// is the class of err and backoff is 0 if the flow gave up.
+	EndAttempt(ctx context.Context, err error, class ErrorClass, backoff time.Duration)
+	// StartStep is called right before a step runs, with its 1-based index
+	// and Name.
+	StartStep(ctx context.Context, step int, name string) context.Context
+	// EndStep is called when the step returns.
+	EndStep(ctx context.Context, err error)
+}
+
+// observers calls several observers in order, each starting from the
+// context returned by the previous one. The context each one returned is
+// kept, so that each End method receives its own.
+type observers []Observer
+
+// observedKey is the context key of the contexts returned by each observer.
+type observedKey struct{}
+
+func (obs observers) start(ctx context.Context, start func(o Observer, ctx context.Context) context.Context) context.Context {
+	ctxs := make([]context.Context, len(obs))
+	for i, o := range obs {
+		ctx = start(o, ctx)
+		ctxs[i] = ctx
+	}
+	return context.WithValue(ctx, observedKey{}, ctxs)
+}
+
+func (obs observers) end(ctx context.Context, end func(o Observer, ctx context.Context)) {
+	ctxs, _ := ctx.Value(observedKey{}).([]context.Context)
+	for i, o := range obs {
+		end(o, ctxs[i])
+	}
+}
+
+func (obs observers) StartFlow(ctx context.Context, name string) context.Context {
+	return obs.start(ctx, func(o Observer, ctx context.Context) context.Context { return o.StartFlow(ctx, name) })
+}
+
+func (obs observers) EndFlow(ctx context.Context, err error) {
+	obs.end(ctx, func(o Observer, ctx context.Context) { o.EndFlow(ctx, err) })
+}
+
+func (obs observers) StartAttempt(ctx context.Context, attempt int) context.Context {
+	return obs.start(ctx, func(o Observer, ctx context.Context) context.Context { return o.StartAttempt(ctx, attempt) })
+}
+
+func (obs observers) EndAttempt(ctx context.Context, err error, class ErrorClass, backoff time.Duration) {
+	obs.end(ctx, func(o Observer, ctx context.Context) { o.EndAttempt(ctx, err, class, backoff) })
+}
+
+func (obs observers) StartStep(ctx context.Context, step int, name string) context.Context {
+	return obs.start(ctx, func(o Observer, ctx context.Context) context.Context { return o.StartStep(ctx, step, name) })
+}
+
+func (obs observers) EndStep(ctx context.Context, err error) {
+	obs.end(ctx, func(o Observer, ctx context.Context) { o.EndStep(ctx, err) })
+}
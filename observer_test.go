@@ -0,0 +1,154 @@
+package retryflow_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Vealcoo/retryflow"
+)
+
+type observerKey struct{}
+
+// tagObserver records the events it sees, tagging each context it returns
+// so that it can check it gets the same context back.
+type tagObserver struct {
+	tag    string
+	events *[]string
+}
+
+func (o tagObserver) start(ctx context.Context, event string) context.Context {
+	*o.events = append(*o.events, o.tag+" "+event)
+	return context.WithValue(ctx, observerKey{}, o.tag+" "+event)
+}
+
+func (o tagObserver) end(ctx context.Context, event string) {
+	if got := ctx.Value(observerKey{}); got != o.tag+" "+event {
+		*o.events = append(*o.events, fmt.Sprintf("%s end %s: wrong context %v", o.tag, event, got))
+	}
+}
+
+func (o tagObserver) StartFlow(ctx context.Context, name string) context.Context {
+	return o.start(ctx, "flow")
+}
+func (o tagObserver) EndFlow(ctx context.Context, err error) { o.end(ctx, "flow") }
+func (o tagObserver) StartAttempt(ctx context.Context, attempt int) context.Context {
+	return o.start(ctx, fmt.Sprint("attempt ", attempt))
+}
+func (o tagObserver) EndAttempt(ctx context.Context, err error, class retryflow.ErrorClass, backoff time.Duration) {
+	o.end(ctx, "attempt 1")
+}
+func (o tagObserver) StartStep(ctx context.Context, step int, name string) context.Context {
+	return o.start(ctx, fmt.Sprint("step ", step))
+}
+func (o tagObserver) EndStep(ctx context.Context, err error) { o.end(ctx, "step 1") }
+
+func TestMultipleObservers(t *testing.T) {
+	var events []string
+	var stepTag any
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			stepTag = ctx.Value(observerKey{})
+			return nil
+		}),
+	),
+		retryflow.WithObserver(tagObserver{"a", &events}),
+		retryflow.WithObserver(tagObserver{"b", &events}),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want := []string{"a flow", "b flow", "a attempt 1", "b attempt 1", "a step 1", "b step 1"}
+	if !slices.Equal(events, want) {
+		t.Errorf("expected events %v, got %v", want, events)
+	}
+	if stepTag != "b step 1" {
+		t.Errorf("expected the step to run with the context of the last observer, got %v", stepTag)
+	}
+}
+
+func TestObserverSeesFailures(t *testing.T) {
+	var ends []string
+	obs := &recordingObserver{ends: &ends}
+	calls := 0
+	_ = retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			calls++
+			if calls == 1 {
+				return errors.New("fail")
+			}
+			return nil
+		}),
+	),
+		retryflow.WithObserver(obs),
+		retryflow.WithErrorClassifier(func(error) retryflow.ErrorClass { return retryflow.ClassTransient }),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	want := []string{"step fail", "attempt attempt 1, step 1: fail transient 1ms", "step <nil>", "attempt <nil>  0s", "flow <nil>"}
+	if !slices.Equal(ends, want) {
+		t.Errorf("expected %v, got %v", want, ends)
+	}
+}
+
+// recordingObserver records how each operation ended.
+type recordingObserver struct{ ends *[]string }
+
+func (o *recordingObserver) StartFlow(ctx context.Context, name string) context.Context { return ctx }
+func (o *recordingObserver) EndFlow(ctx context.Context, err error) {
+	*o.ends = append(*o.ends, fmt.Sprint("flow ", err))
+}
+func (o *recordingObserver) StartAttempt(ctx context.Context, attempt int) context.Context {
+	return ctx
+}
+func (o *recordingObserver) EndAttempt(ctx context.Context, err error, class retryflow.ErrorClass, backoff time.Duration) {
+	*o.ends = append(*o.ends, fmt.Sprint("attempt ", err, " ", class, " ", backoff))
+}
+func (o *recordingObserver) StartStep(ctx context.Context, step int, name string) context.Context {
+	return ctx
+}
+func (o *recordingObserver) EndStep(ctx context.Context, err error) {
+	*o.ends = append(*o.ends, fmt.Sprint("step ", err))
+}
+
+// firstAttemptObserver tags the context of the first attempt only.
+type firstAttemptObserver struct{ recordingObserver }
+
+func (o *firstAttemptObserver) StartAttempt(ctx context.Context, attempt int) context.Context {
+	if attempt == 1 {
+		return context.WithValue(ctx, observerKey{}, "attempt 1")
+	}
+	return ctx
+}
+
+func TestAttemptContextIsFresh(t *testing.T) {
+	var ends []string
+	var tags []any
+	var firstCtx context.Context
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			tags = append(tags, ctx.Value(observerKey{}))
+			if firstCtx == nil {
+				firstCtx = ctx
+				return errors.New("fail")
+			}
+			if firstCtx.Err() != context.Canceled {
+				t.Errorf("expected the first attempt's context to be canceled, got %v", firstCtx.Err())
+			}
+			return nil
+		}),
+	),
+		retryflow.WithObserver(&firstAttemptObserver{recordingObserver{ends: &ends}}),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(tags) != 2 || tags[0] != "attempt 1" || tags[1] != nil {
+		t.Errorf("expected the first attempt's value not to reach the second, got %v", tags)
+	}
+}
@@ -1,9 +1,10 @@
 package retryflow
 
 import (
+	"context"
+	"math/rand"
+	"sync/atomic"
 	"time"
-
-	"golang.org/x/time/rate"
 )
 
 // Option defines a function to configure retry options.
@@ -11,21 +12,85 @@ type Option func(*options)
 
 // options holds the configuration for the retry mechanism.
 type options struct {
-	initialBackoff  time.Duration
-	maxBackoff      time.Duration
-	jitter          time.Duration
-	maxRetries      int
-	maxElapsedTime  time.Duration
-	onRetry         func(attempt int, err error)
-	onAttemptStart  func(attempt int)
-	onStepSuccess   func(step int, output any)
-	backoffStrategy func(attempt int, prev time.Duration) time.Duration
-	retryable       func(err error) bool
-	perErrorLimits  errorClassLimit
-	errorClassifier func(err error) ErrorClass
-	rateLimiter     *rate.Limiter
+	initialBackoff     time.Duration
+	maxBackoff         time.Duration
+	jitter             time.Duration
+	jitterFraction     float64
+	jitterMode         JitterMode
+	adaptiveJitter     bool
+	rand               *lockedRand
+	maxRetries         int
+	maxElapsedTime     time.Duration
+	deadline           time.Time
+	maxIterations      int
+	onRetry            func(attempt int, err error)
+	onAttemptStart     func(attempt int)
+	onStepStart        func(step int, input any)
+	onStart            func(config ConfigSnapshot)
+	onStepSuccess      func(step int, output any)
+	onNamedStepSuccess func(step int, name string, output any)
+	onCheckpoint       func(step int, output any)
+	onStepOutputDiff   func(step int, prev, curr any)
+	onGiveUp           func(attempt int, err error, reason GiveUpReason)
+	onStepSkip         func(step int, reason SkipReason)
+	onWarning          func(err error)
+	logger             Logger
+	flowName           string
+	idempotencyKey     string
+	strictValidation   bool
+	backoffStrategy    func(attempt int, prev time.Duration) time.Duration
+	backoffFunc        func(attempt int, elapsed, prev time.Duration) time.Duration
+	decorrelatedBase   time.Duration
+	decorrelatedCap    time.Duration
+	loadSource         func() float64
+	loadFactor         float64
+	retryable          func(err error) bool
+	retryableClasses   map[ErrorClass]bool
+	restartClasses     map[ErrorClass]bool
+	perErrorLimits     errorClassLimit
+	maxDistinctClasses int
+	errorClassifier    func(err error) ErrorClass
+	multiErrorPolicy   MultiErrorPolicy
+	jitterClasses      map[ErrorClass]bool
+	minBackoffByClass  map[ErrorClass]time.Duration
+	wakeup             <-chan struct{}
+	readinessProbe     func(ctx context.Context) bool
+	preflight          func(ctx context.Context) error
+	scheduleGuard      func(now time.Time) (allow bool, delay time.Duration)
+	clock              Clock
+	rateLimiter        Limiter
+	circuitBreaker     CircuitBreaker
+	retryBudget        *RetryBudget
+	maxQueued          int
+	queued             *atomic.Int64 // waiters counted against maxQueued
+	// abort batch steps whose failure rate exceeds errorRateThreshold
+	errorRateThreshold  float64
+	errorRateMinSamples int
+	stats               *Stats
+	maxEventHistory     int
+	results             *Results
+	flowKey             string
+	canceler            *Canceler
+	observer            Observer
+	captureSteps        map[int]bool
+	autoCheckpointEvery int
+	checkpointStore     CheckpointStore
+	checkpointKey       string
+	wholeFlowRetry      bool
+	compensationContext func() context.Context
+	compensateAll       bool
+	outputCoercion      bool
+	recoverPanic        bool
+	retryablePanic      func(recovered any) bool
+	panicClass          ErrorClass
 	// default reset error limit on checkpoint
 	resetErrorLimitOnCheckpoint bool
+	resetBackoffOnClassChange   bool
+	collapseRetries             bool
+	checkpointCooldown          time.Duration
+	sameOutputStep              int
+	maxSameOutput               int
+	labels                      map[string]int // 0-based step index of each label, set by buildOptions
 }
 
 // defaultOptions returns the default retry configuration.
@@ -34,11 +99,13 @@ func defaultOptions() options {
 		initialBackoff:              500 * time.Millisecond,
 		maxBackoff:                  30 * time.Second,
 		jitter:                      200 * time.Millisecond,
+		jitterMode:                  JitterAdditive,
 		maxRetries:                  5, // Changed to finite default to avoid infinite loops
 		maxElapsedTime:              5 * time.Minute,
+		maxIterations:               10000,
 		backoffStrategy:             ExponentialBackoff,
-		retryable:                   func(err error) bool { return true },
 		errorClassifier:             func(err error) ErrorClass { return NewErrorClass(err) },
+		clock:                       realClock{},
 		resetErrorLimitOnCheckpoint: true,
 	}
 }
@@ -59,10 +126,16 @@ func WithOnStepSuccess(f func(step int, output any)) Option {
 	return func(o *options) { o.onStepSuccess = f }
 }
 func WithBackoffStrategy(f func(attempt int, prev time.Duration) time.Duration) Option {
-	return func(o *options) { o.backoffStrategy = f }
+	return func(o *options) {
+		o.backoffStrategy = f
+		o.decorrelatedCap = 0
+	}
 }
 func WithRetryable(f func(err error) bool) Option {
-	return func(o *options) { o.retryable = f }
+	return func(o *options) {
+		o.retryable = f
+		o.retryableClasses = nil
+	}
 }
 func WithPerErrorLimits(limits errorClassLimit) Option {
 	return func(o *options) { o.perErrorLimits = limits }
@@ -70,9 +143,469 @@ func WithPerErrorLimits(limits errorClassLimit) Option {
 func WithErrorClassifier(f func(err error) ErrorClass) Option {
 	return func(o *options) { o.errorClassifier = f }
 }
-func WithRateLimiter(limiter *rate.Limiter) Option {
+func WithRateLimiter(limiter Limiter) Option {
 	return func(o *options) { o.rateLimiter = limiter }
 }
 func WithResetErrorLimitOnCheckpoint(b bool) Option {
 	return func(o *options) { o.resetErrorLimitOnCheckpoint = b }
 }
+func WithStats(s *Stats) Option {
+	return func(o *options) { o.stats = s }
+}
+
+// WithJitterClasses applies jitter only to failures whose error class is one of
+// classes. Failures of any other class sleep the plain computed backoff.
+func WithJitterClasses(classes ...ErrorClass) Option {
+	return func(o *options) {
+		o.jitterClasses = make(map[ErrorClass]bool, len(classes))
+		for _, c := range classes {
+			o.jitterClasses[c] = true
+		}
+	}
+}
+
+// WithErrorRateThreshold aborts the flow, instead of retrying, when a ForEach
+// step fails on more than fraction of its items. The rate is checked as the
+// items run, once at least minSamples have, so that the rest of the batch is
+// not run against a dependency that is down.
+func WithErrorRateThreshold(fraction float64, minSamples int) Option {
+	return func(o *options) {
+		o.errorRateThreshold = fraction
+		o.errorRateMinSamples = minSamples
+	}
+}
+
+// WithOutputCoercion converts step outputs that are not assignable but are
+// convertible to the expected type, such as int into int64 or a named type
+// into its underlying type, both when storing with Do and when passing the
+// output to the next Chain step. Numbers that do not fit the expected type,
+// and floats with a fraction expected as integers, are not converted.
+func WithOutputCoercion(b bool) Option {
+	return func(o *options) { o.outputCoercion = b }
+}
+
+// WithWakeupChannel ends a backoff sleep early when ch receives a value or is
+// closed, so the next attempt starts immediately.
+func WithWakeupChannel(ch <-chan struct{}) Option {
+	return func(o *options) { o.wakeup = ch }
+}
+
+// WithRecoverPanic recovers panics raised by steps and turns them into a
+// *PanicError that goes through the normal retryable and classifier checks.
+// Panics are not retried unless WithPanicClass makes them retryable.
+func WithRecoverPanic(b bool) Option {
+	return func(o *options) { o.recoverPanic = b }
+}
+
+// WithRetryablePanic decides from the raw recovered value whether a panicking
+// step should be retried, taking precedence over WithRetryable for panics.
+// It implies WithRecoverPanic(true).
+func WithRetryablePanic(f func(recovered any) bool) Option {
+	return func(o *options) {
+		o.recoverPanic = true
+		o.retryablePanic = f
+	}
+}
+
+// WithClock sets the clock used for elapsed time and backoff sleeps. Since
+// context deadlines follow the real clock, steps are then not cancelled when
+// WithMaxElapsedTime or WithDeadline runs out; the budget is only checked
+// after failures, on c.
+func WithClock(c Clock) Option {
+	return func(o *options) { o.clock = c }
+}
+
+// WithScheduleGuard consults f with the current time before each retry.
+// Returning allow=false gives up with the last error; otherwise delay is
+// added to the backoff sleep, e.g. to push retries into an off-peak window.
+func WithScheduleGuard(f func(now time.Time) (allow bool, delay time.Duration)) Option {
+	return func(o *options) { o.scheduleGuard = f }
+}
+
+// WithMultiErrorPolicy sets how joined errors are classified.
+// The default is MultiErrorMostSevere.
+func WithMultiErrorPolicy(p MultiErrorPolicy) Option {
+	return func(o *options) { o.multiErrorPolicy = p }
+}
+
+// WithAutoCheckpointEvery treats every nth step (n, 2n, ...) as a checkpoint
+// without marking it with Checkpoint. Steps marked with Checkpoint remain
+// checkpoints regardless of n.
+func WithAutoCheckpointEvery(n int) Option {
+	return func(o *options) { o.autoCheckpointEvery = n }
+}
+
+// WithCaptureSteps limits the outputs collected in Stats.Outputs to the given
+// 1-based step indices. Outputs of other steps are still passed along the
+// chain but not retained.
+func WithCaptureSteps(indices ...int) Option {
+	return func(o *options) {
+		o.captureSteps = make(map[int]bool, len(indices))
+		for _, i := range indices {
+			o.captureSteps[i] = true
+		}
+	}
+}
+
+// WithFlowKey deduplicates concurrent Retry calls made with the same key:
+// while one flow with the key is running, later calls wait for it and
+// receive its error instead of executing their own steps. Only the running
+// flow's steps, hooks and output pointers are used. A caller whose ctx ends
+// stops waiting and returns ctx.Err(); the flow keeps running for the other
+// callers and is cancelled once none is left.
+func WithFlowKey(key string) Option {
+	return func(o *options) { o.flowKey = key }
+}
+
+// WithSimpleExponential configures exponential backoff starting at base and
+// doubling up to cap, with a jitter of ±jitterFraction of each delay.
+func WithSimpleExponential(base, cap time.Duration, jitterFraction float64) Option {
+	return func(o *options) {
+		o.initialBackoff = base
+		o.maxBackoff = cap
+		o.backoffStrategy = ExponentialBackoff
+		o.decorrelatedCap = 0
+		o.jitter = 0
+		o.jitterFraction = jitterFraction
+	}
+}
+
+// WithOnStepSkip sets a hook called with the 1-based step index and the
+// reason whenever a step is skipped.
+func WithOnStepSkip(f func(step int, reason SkipReason)) Option {
+	return func(o *options) { o.onStepSkip = f }
+}
+
+// WithOnStart sets a hook called once when the flow starts with a snapshot
+// of its effective configuration.
+func WithOnStart(f func(config ConfigSnapshot)) Option {
+	return func(o *options) { o.onStart = f }
+}
+
+// WithMaxIterations caps the total number of attempts of a flow, counted
+// across checkpoints, as a safety net against flows whose checkpoints keep
+// resetting maxRetries. Exceeding it returns a *LoopDetectedError.
+// Zero disables the check.
+func WithMaxIterations(n int) Option {
+	return func(o *options) { o.maxIterations = n }
+}
+
+// WithReadinessProbe polls probe every few milliseconds during backoff
+// sleeps and starts the next attempt early once it reports true.
+func WithReadinessProbe(probe func(ctx context.Context) bool) Option {
+	return func(o *options) { o.readinessProbe = probe }
+}
+
+// WithOnWarning sets a hook receiving suspicious configuration, such as
+// ErrJitterExceedsBackoff, detected when the flow starts.
+func WithOnWarning(f func(err error)) Option {
+	return func(o *options) { o.onWarning = f }
+}
+
+// WithStrictValidation makes configuration warnings fail Retry instead of
+// being passed to the WithOnWarning hook.
+func WithStrictValidation(b bool) Option {
+	return func(o *options) { o.strictValidation = b }
+}
+
+// WithWholeFlowRetry ignores checkpoints and RetryFrom labels so that every
+// retry re-runs the whole sequence from the first step. Use it for
+// idempotent flows where resuming could leave inconsistent state.
+func WithWholeFlowRetry(b bool) Option {
+	return func(o *options) { o.wholeFlowRetry = b }
+}
+
+// WithBackoffFunc sets a backoff strategy that also receives the time elapsed
+// since the flow started, so schedules can escalate the longer a flow keeps
+// failing. It takes precedence over WithBackoffStrategy.
+func WithBackoffFunc(f func(attempt int, elapsed, prev time.Duration) time.Duration) Option {
+	return func(o *options) { o.backoffFunc = f }
+}
+
+// WithMaxDistinctErrorClasses gives up once the flow has seen more than n
+// distinct error classes, a sign of systemic instability that per-class
+// limits miss. Zero disables the check.
+func WithMaxDistinctErrorClasses(n int) Option {
+	return func(o *options) { o.maxDistinctClasses = n }
+}
+
+// WithJitterMode sets how jitter is applied to the computed backoff. The
+// WithJitter duration and WithJitterFraction only affect JitterAdditive;
+// JitterFull and JitterEqual derive their range from the backoff itself.
+func WithJitterMode(m JitterMode) Option {
+	return func(o *options) { o.jitterMode = m }
+}
+
+// WithPreflightCheck runs check before the first step of the flow. While it
+// fails, no step runs and the failure is retried like a step error, subject
+// to the same backoff and limits; the error is an *AttemptError with Step 0.
+// Once the check passes it is not run again.
+func WithPreflightCheck(check func(ctx context.Context) error) Option {
+	return func(o *options) { o.preflight = check }
+}
+
+// WithOnStepOutputDiff sets a debugging hook called when a re-run step
+// succeeds with an output that differs, per reflect.DeepEqual, from its
+// previous successful run, surfacing non-deterministic steps.
+func WithOnStepOutputDiff(f func(step int, prev, curr any)) Option {
+	return func(o *options) { o.onStepOutputDiff = f }
+}
+
+// WithOnGiveUp sets a hook called exactly once, right before Retry returns,
+// when the flow stops retrying a failure. err is the error Retry returns,
+// usually an *AttemptError; for GiveUpCanceled it is the context error.
+// A context that ends before any attempt failed does not fire the hook.
+func WithOnGiveUp(f func(attempt int, err error, reason GiveUpReason)) Option {
+	return func(o *options) { o.onGiveUp = f }
+}
+
+// WithMinBackoffByClass floors the sleep after a failure of a listed class,
+// jitter included, to the class minimum, e.g. so a rate-limited request is
+// never retried sooner than the server asks for.
+func WithMinBackoffByClass(floors map[ErrorClass]time.Duration) Option {
+	return func(o *options) { o.minBackoffByClass = floors }
+}
+
+// WithOnCheckpoint sets a hook called with the 1-based step index and the
+// output each time a checkpoint commits, e.g. to persist progress.
+func WithOnCheckpoint(f func(step int, output any)) Option {
+	return func(o *options) { o.onCheckpoint = f }
+}
+
+// WithMaxQueuedRetries bounds how many attempts may wait on the
+// WithRateLimiter limiter at once. An attempt that would exceed n fails the
+// flow with ErrLimiterQueueFull instead of blocking. The waiters are counted
+// across all flows applying the returned option, so create it once per
+// limiter and pass the same value to every flow sharing the limiter.
+func WithMaxQueuedRetries(n int) Option {
+	queued := new(atomic.Int64)
+	return func(o *options) {
+		o.maxQueued = n
+		o.queued = queued
+	}
+}
+
+// WithCheckpointStore persists checkpoints in store under key. When the flow
+// starts it resumes from the checkpoint loaded from store, and every
+// committed checkpoint is saved, so a flow can survive a process restart.
+// The checkpoint is kept after the flow succeeds; clear it with Save(ctx,
+// key, 0, nil) before reusing the key for a new flow.
+func WithCheckpointStore(store CheckpointStore, key string) Option {
+	return func(o *options) {
+		o.checkpointStore = store
+		o.checkpointKey = key
+	}
+}
+
+// WithCircuitBreaker consults cb before each attempt and fails the flow with
+// ErrCircuitOpen when it rejects the attempt. Share cb between flows calling
+// the same dependency so that they stop retrying together.
+func WithCircuitBreaker(cb CircuitBreaker) Option {
+	return func(o *options) { o.circuitBreaker = cb }
+}
+
+// WithResetBackoffOnClassChange resets the backoff to the initial value
+// whenever a failure is classified differently from the previous one, so a
+// long rate limit backoff is not carried into a quick transient retry. The
+// strategy then starts over, receiving attempt 1 for that failure.
+func WithResetBackoffOnClassChange(b bool) Option {
+	return func(o *options) { o.resetBackoffOnClassChange = b }
+}
+
+// WithRetryBudget makes every retry take a token from budget and gives up
+// with ErrBudgetExhausted once it is empty, even if maxRetries would allow
+// more. Share budget between flows to cap their retries together.
+func WithRetryBudget(budget *RetryBudget) Option {
+	return func(o *options) { o.retryBudget = budget }
+}
+
+// WithCompensationContext sets the context given to Compensate handlers when
+// the flow's own context is already done, e.g. cancelled mid-way. By default
+// they get a context detached from the cancellation with a 5s deadline.
+func WithCompensationContext(f func() context.Context) Option {
+	return func(o *options) { o.compensationContext = f }
+}
+
+// WithLogger sets a structured logger. Attempt starts and step successes are
+// logged at Debug, retries with their backoff and checkpoints at Info, and
+// giving up at Warn, tagged with the flow name, attempt, step and, for
+// failures, the error class. By default, as with NopLogger, nothing is logged
+// and no fields are built.
+func WithLogger(l Logger) Option {
+	return func(o *options) {
+		if _, ok := l.(NopLogger); ok {
+			l = nil
+		}
+		o.logger = l
+	}
+}
+
+// WithFlowName names the flow in log entries.
+func WithFlowName(name string) Option {
+	return func(o *options) { o.flowName = name }
+}
+
+// WithCompensateAll makes a failing flow compensate every step that
+// succeeded, including those committed by checkpoints, instead of only the
+// steps since the last checkpoint.
+func WithCompensateAll(b bool) Option {
+	return func(o *options) { o.compensateAll = b }
+}
+
+// WithLoadSource lengthens every backoff by a factor of (1 + load*k), where
+// load is read from src before each sleep and clamped to [0, 1], so waits
+// grow with the load reported by the dependency. The backoff strategy still
+// sees the unscaled delays.
+func WithLoadSource(src func() float64, k float64) Option {
+	return func(o *options) {
+		o.loadSource = src
+		o.loadFactor = k
+	}
+}
+
+// WithOnStepStart sets a hook called with the 1-based step index and the
+// step's input right before the step runs, on every attempt.
+func WithOnStepStart(f func(step int, input any)) Option {
+	return func(o *options) { o.onStepStart = f }
+}
+
+// WithDeadline gives up once the flow reaches the absolute time t, like
+// WithMaxElapsedTime with the duration left until t when the flow starts.
+// The sooner of the two applies. t is read only at the start and elapsed
+// time is measured from then on, which the default clock does on the
+// monotonic clock, so later wall clock adjustments do not affect the budget.
+func WithDeadline(t time.Time) Option {
+	return func(o *options) { o.deadline = t }
+}
+
+// WithPanicClass sets the class of the *PanicError of recovered panics,
+// ClassPermanent by default, which is not retried. Any other class makes
+// panics retryable and subject to that class's limits.
+func WithPanicClass(c ErrorClass) Option {
+	return func(o *options) { o.panicClass = c }
+}
+
+// WithCanceler registers the flow with c while it runs, so that c.Cancel
+// stops it without access to the context passed to Retry. Several flows may
+// share c; Cancel stops all of them.
+func WithCanceler(c *Canceler) Option {
+	return func(o *options) { o.canceler = c }
+}
+
+// WithCollapseRepeatedRetries makes WithOnRetry fire once per streak of
+// consecutive failures with the same class and message instead of once per
+// failure. The hook receives a *RepeatedError counting the streak when the
+// error changes or the flow ends.
+func WithCollapseRepeatedRetries(b bool) Option {
+	return func(o *options) { o.collapseRetries = b }
+}
+
+// WithRetryableClasses retries only failures whose error class, as decided by
+// WithErrorClassifier or the failed step's Classify, is one of classes. It
+// replaces WithRetryable, and the last of the two options wins.
+func WithRetryableClasses(classes ...ErrorClass) Option {
+	return func(o *options) {
+		o.retryable = nil
+		o.retryableClasses = make(map[ErrorClass]bool, len(classes))
+		for _, c := range classes {
+			o.retryableClasses[c] = true
+		}
+	}
+}
+
+// WithObserver adds an Observer following the flow's attempts and steps.
+// Observers added by several WithObserver options are all called, in order.
+func WithObserver(obs Observer) Option {
+	return func(o *options) {
+		if o.observer != nil {
+			obs = observers{o.observer, obs}
+		}
+		o.observer = obs
+	}
+}
+
+// WithCheckpointCooldown pauses for d after each checkpoint commits, before
+// the next step runs, to pace flows made of several stages. Unlike backoff
+// sleeps it is not cut short by WithWakeupChannel or WithReadinessProbe, only
+// by the context.
+func WithCheckpointCooldown(d time.Duration) Option {
+	return func(o *options) { o.checkpointCooldown = d }
+}
+
+// WithResults records in r the output of every named step each time it
+// succeeds. Read them with Results.Get or GetTyped.
+func WithResults(r *Results) Option {
+	return func(o *options) { o.results = r }
+}
+
+// WithMaxSameOutput gives up with a *StuckError when the 1-based step keeps
+// succeeding with the same output, compared with reflect.DeepEqual, n times
+// in a row after its first such output. It detects polling flows that do not
+// converge.
+func WithMaxSameOutput(step, n int) Option {
+	return func(o *options) {
+		o.sameOutputStep = step
+		o.maxSameOutput = n
+	}
+}
+
+// WithIdempotencyKey sets the key returned by IdempotencyKeyFromContext
+// instead of a generated one, e.g. to keep it across process restarts.
+func WithIdempotencyKey(key string) Option {
+	return func(o *options) { o.idempotencyKey = key }
+}
+
+// WithMaxEventHistory bounds the per-attempt history kept in
+// Stats.ExecutedSteps to the latest n attempts, dropping older ones, so that
+// long-running flows do not grow without limit. 0 keeps all of it.
+func WithMaxEventHistory(n int) Option {
+	return func(o *options) { o.maxEventHistory = n }
+}
+
+// WithAdaptiveJitter widens the JitterAdditive window with each consecutive
+// failure of the same class, up to the computed backoff, to spread clients
+// contending for a resource further apart. The window returns to its base
+// size when the class changes or the step that failed succeeds.
+func WithAdaptiveJitter(b bool) Option {
+	return func(o *options) { o.adaptiveJitter = b }
+}
+
+// WithRand draws jitter from r instead of a source seeded from the time, to
+// make schedules reproducible. Flows started with the same option share r
+// under a lock; r must not be used elsewhere while they run.
+func WithRand(r *rand.Rand) Option {
+	locked := &lockedRand{r: r}
+	return func(o *options) { o.rand = locked }
+}
+
+// WithOnNamedStepSuccess is like WithOnStepSuccess but also passes the name
+// set with Step.Name, empty for unnamed steps. Failures carry the name in
+// the *AttemptError that WithOnRetry receives.
+func WithOnNamedStepSuccess(f func(step int, name string, output any)) Option {
+	return func(o *options) { o.onNamedStepSuccess = f }
+}
+
+// WithRestartClasses makes failures whose error class is one of classes
+// discard the checkpoint, so that the next attempt starts again from the
+// first step instead of resuming, e.g. to log in again after ClassAuth
+// errors. Per-error limits and the other limits apply as usual.
+func WithRestartClasses(classes ...ErrorClass) Option {
+	return func(o *options) {
+		o.restartClasses = make(map[ErrorClass]bool, len(classes))
+		for _, c := range classes {
+			o.restartClasses[c] = true
+		}
+	}
+}
+
+// WithDecorrelatedJitter uses the "decorrelated jitter" algorithm as the
+// backoff strategy, sleep = min(cap, random_between(base, prev*3)), drawing
+// from the flow's random source like the jitter, so WithRand makes the
+// sleeps reproducible. It replaces WithBackoffStrategy, and the last of the
+// two options wins. WithMaxBackoff still applies on top of cap.
+func WithDecorrelatedJitter(base, cap time.Duration) Option {
+	return func(o *options) {
+		o.decorrelatedBase = base
+		o.decorrelatedCap = cap
+	}
+}
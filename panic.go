@@ -0,0 +1,51 @@
+package retryflow
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError wraps a value recovered from a panicking step.
+// It implements Classifier with the class set by WithPanicClass,
+// ClassPermanent by default, and is not retried while that class is
+// ClassPermanent.
+type PanicError struct {
+	Value any
+	Stack []byte // stack trace of the panicking goroutine
+	class ErrorClass
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Class returns the class configured with WithPanicClass.
+func (e *PanicError) Class() ErrorClass {
+	if e.class == "" {
+		return ClassPermanent
+	}
+	return e.class
+}
+
+// Permanent reports whether the panic is classified as ClassPermanent.
+func (e *PanicError) Permanent() bool {
+	return e.Class() == ClassPermanent
+}
+
+// runRecovered runs the step and converts a panic into a *PanicError of class.
+// A *PanicError raised again by Parallel for a panicking child keeps its
+// value and the stack of the child's goroutine.
+func runRecovered(ctx context.Context, step *Step, input any, class ErrorClass) (output any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			pe, ok := r.(*PanicError)
+			if !ok {
+				pe = &PanicError{Value: r, Stack: debug.Stack()}
+			}
+			pe.class = class
+			output, err = nil, pe
+		}
+	}()
+	return step.run(ctx, input)
+}
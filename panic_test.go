@@ -0,0 +1,137 @@
+package retryflow_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Vealcoo/retryflow"
+)
+
+var errRetryPanic = errors.New("retry me")
+
+func TestRetryablePanic(t *testing.T) {
+	tests := []struct {
+		name         string
+		value        any
+		wantAttempts int
+		wantErr      bool
+	}{
+		{"SentinelRetries", errRetryPanic, 3, false},
+		{"OtherAborts", "boom", 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			steps := retryflow.Seq(
+				retryflow.Exec(func(ctx context.Context) error {
+					attempts++
+					if attempts < 3 {
+						panic(tt.value)
+					}
+					return nil
+				}),
+			)
+
+			err := retryflow.Retry(context.Background(), steps,
+				retryflow.WithInitialBackoff(time.Millisecond),
+				retryflow.WithJitter(0),
+				retryflow.WithRetryablePanic(func(recovered any) bool {
+					return recovered == errRetryPanic
+				}),
+			)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error=%v, got %v", tt.wantErr, err)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("expected %d attempts, got %d", tt.wantAttempts, attempts)
+			}
+			var panicErr *retryflow.PanicError
+			if tt.wantErr && (!errors.As(err, &panicErr) || panicErr.Value != tt.value) {
+				t.Errorf("expected PanicError with value %v, got %v", tt.value, err)
+			}
+		})
+	}
+}
+
+func TestRecoveredPanicUsesClassifier(t *testing.T) {
+	attempts := 0
+	steps := retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			attempts++
+			var m map[string]int
+			m["boom"] = 1
+			return nil
+		}),
+	)
+
+	err := retryflow.Retry(context.Background(), steps,
+		retryflow.WithRecoverPanic(true),
+		retryflow.WithPanicClass(retryflow.ClassTransient), // retryable, classified below
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithErrorClassifier(func(err error) retryflow.ErrorClass {
+			if errors.As(err, new(*retryflow.PanicError)) {
+				return retryflow.ClassPermanent
+			}
+			return retryflow.ClassUnknown
+		}),
+		retryflow.WithPerErrorLimits(retryflow.NewErrorClassLimit().AddLimit(retryflow.ClassPermanent, 1)),
+	)
+	if !errors.As(err, new(*retryflow.PanicError)) {
+		t.Fatalf("expected PanicError, got %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+}
+
+func TestPanicClass(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []retryflow.Option
+		wantClass    retryflow.ErrorClass
+		wantAttempts int
+	}{
+		{"DefaultPermanent", nil, retryflow.ClassPermanent, 1},
+		{"Transient", []retryflow.Option{retryflow.WithPanicClass(retryflow.ClassTransient)}, retryflow.ClassTransient, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := retryflow.Retry(context.Background(), retryflow.Seq(
+				retryflow.Exec(func(ctx context.Context) error {
+					attempts++
+					panicInStep()
+					return nil
+				}),
+			), append([]retryflow.Option{
+				retryflow.WithRecoverPanic(true),
+				retryflow.WithMaxRetries(3),
+				retryflow.WithInitialBackoff(time.Millisecond),
+				retryflow.WithJitter(0),
+			}, tt.opts...)...)
+
+			var panicErr *retryflow.PanicError
+			if !errors.As(err, &panicErr) {
+				t.Fatalf("expected PanicError, got %v", err)
+			}
+			if panicErr.Class() != tt.wantClass || retryflow.NewErrorClass(panicErr) != tt.wantClass {
+				t.Errorf("expected class %v, got %v", tt.wantClass, panicErr.Class())
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("expected %d attempts, got %d", tt.wantAttempts, attempts)
+			}
+			if !strings.Contains(string(panicErr.Stack), "panicInStep") {
+				t.Errorf("expected the stack trace to name the panicking function, got:\n%s", panicErr.Stack)
+			}
+		})
+	}
+}
+
+func panicInStep() {
+	panic("boom")
+}
@@ -0,0 +1,134 @@
+package retryflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Parallel creates a step that runs steps concurrently with the same input
+// and outputs their results as a []any in declaration order.
+// The children share a context that is cancelled as soon as one of them
+// fails, and the group fails with the children's errors joined, leaving out
+// the cancellation errors of the siblings it stopped. Outputs are delivered
+// to the children's Do pointers and Store sinks only when all of them succeed.
+// A panicking child stops its siblings and panics in the flow's goroutine,
+// where WithRecoverPanic turns it into a *PanicError as for any step.
+//
+// The children only run their function and deliver their output: Retry
+// rejects children configured with step settings that apply to steps of the
+// sequence, such as Checkpoint, Timeout, When, Optional, Retryable,
+// Classify or Compensate. Set those on the Parallel step instead.
+func Parallel(steps ...*Step) *Step {
+	children := append([]*Step(nil), steps...)
+	s := &Step{children: children}
+	s.run = func(ctx context.Context, input any) (any, error) {
+		g, gctx := errgroup.WithContext(ctx)
+		outputs := make([]any, len(children))
+		errs := make([]error, len(children))
+		panics := make([]*PanicError, len(children))
+		for i, child := range children {
+			g.Go(func() (err error) {
+				defer func() {
+					if r := recover(); r != nil {
+						panics[i] = &PanicError{Value: r, Stack: debug.Stack()}
+						err = panics[i]
+					}
+				}()
+				out, err := child.run(gctx, input)
+				if err != nil {
+					errs[i] = err
+					return err
+				}
+				outputs[i] = out
+				return nil
+			})
+		}
+		err := g.Wait()
+		for _, p := range panics {
+			if p != nil {
+				panic(p)
+			}
+		}
+		if err != nil {
+			for i, err := range errs {
+				if ctx.Err() == nil && errors.Is(err, context.Canceled) {
+					errs[i] = nil // stopped by the failing sibling
+				}
+			}
+			if joined := errors.Join(errs...); joined != nil {
+				return nil, joined
+			}
+			return nil, err
+		}
+		for i, child := range children {
+			if err := child.deliver(outputs[i], false); err != nil {
+				return nil, err
+			}
+		}
+		return outputs, nil
+	}
+	return s
+}
+
+// unsupportedChildSetting returns the name of a setting of step that
+// Parallel does not apply to its children, or "" if there is none.
+func unsupportedChildSetting(step *Step) string {
+	switch {
+	case step.checkpoint:
+		return "Checkpoint"
+	case step.onFail != nil:
+		return "OnFail"
+	case step.when != nil:
+		return "When"
+	case step.optional:
+		return "Optional"
+	case step.minBudget > 0:
+		return "MinBudget"
+	case step.budgetFrac > 0:
+		return "BudgetFraction"
+	case step.timeout > 0:
+		return "Timeout"
+	case step.jitter != nil:
+		return "Jitter"
+	case step.compensate != nil:
+		return "Compensate"
+	case step.retryable != nil:
+		return "Retryable"
+	case step.classify != nil:
+		return "Classify"
+	case step.maxAttempts > 0:
+		return "MaxAttempts"
+	case step.label != "":
+		return "Label"
+	case step.retryFrom != "":
+		return "RetryFrom"
+	}
+	return ""
+}
+
+// checkParallel reports the first Parallel child of steps, at any depth,
+// with a setting that Parallel does not apply.
+func (s Steps) checkParallel() error {
+	for i, step := range s {
+		if err := checkChildren(step); err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func checkChildren(step *Step) error {
+	for j, child := range step.children {
+		if name := unsupportedChildSetting(child); name != "" {
+			return fmt.Errorf("Parallel child %d: %s is not supported on Parallel children", j+1, name)
+		}
+		if err := checkChildren(child); err != nil {
+			return fmt.Errorf("Parallel child %d: %w", j+1, err)
+		}
+	}
+	return nil
+}
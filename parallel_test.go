@@ -0,0 +1,193 @@
+package retryflow_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Vealcoo/retryflow"
+)
+
+func TestParallelFailureCancelsSiblings(t *testing.T) {
+	errFast := errors.New("fast failure")
+	siblingErrs := make(chan error, 2)
+
+	waitForCancel := func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			siblingErrs <- ctx.Err()
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			siblingErrs <- nil
+			return nil
+		}
+	}
+
+	start := time.Now()
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Parallel(
+			retryflow.Exec(waitForCancel),
+			retryflow.Exec(func(ctx context.Context) error { return errFast }),
+			retryflow.Exec(waitForCancel),
+		),
+	), retryflow.WithMaxRetries(1))
+	if !errors.Is(err, errFast) {
+		t.Fatalf("expected fast failure, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("siblings were not cancelled promptly: %v", elapsed)
+	}
+	for range 2 {
+		if got := <-siblingErrs; !errors.Is(got, context.Canceled) {
+			t.Errorf("expected sibling context to be cancelled, got %v", got)
+		}
+	}
+}
+
+func TestParallelRunsConcurrently(t *testing.T) {
+	const n = 3
+	var started sync.WaitGroup
+	started.Add(n)
+	barrier := func(ctx context.Context) error {
+		started.Done()
+		done := make(chan struct{})
+		go func() { started.Wait(); close(done) }()
+		select {
+		case <-done:
+			return nil
+		case <-time.After(time.Second):
+			return errors.New("children did not run concurrently")
+		}
+	}
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Parallel(retryflow.Exec(barrier), retryflow.Exec(barrier), retryflow.Exec(barrier)),
+	), retryflow.WithMaxRetries(1))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestParallelOutputOrder(t *testing.T) {
+	delayed := func(d time.Duration, v string) *retryflow.Step {
+		return retryflow.Chain(func(ctx context.Context, in string) (string, error) {
+			time.Sleep(d)
+			return in + v, nil
+		})
+	}
+	var first string
+	var outputs []any
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (string, error) { return "in-", nil }),
+		retryflow.Parallel(
+			delayed(20*time.Millisecond, "a").Do(&first),
+			delayed(0, "b"),
+			delayed(10*time.Millisecond, "c"),
+		),
+		retryflow.Chain(func(ctx context.Context, in []any) ([]any, error) { return in, nil }).Do(&outputs),
+	))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if fmt.Sprint(outputs) != "[in-a in-b in-c]" {
+		t.Errorf("expected outputs in declaration order, got %v", outputs)
+	}
+	if first != "in-a" {
+		t.Errorf("expected the child Do pointer to receive in-a, got %q", first)
+	}
+}
+
+func TestParallelJoinsErrors(t *testing.T) {
+	errA := errors.New("a failed")
+	errB := errors.New("b failed")
+	var ready sync.WaitGroup
+	ready.Add(2)
+	failAfterBoth := func(err error) *retryflow.Step {
+		return retryflow.Exec(func(ctx context.Context) error {
+			ready.Done()
+			ready.Wait()
+			return err
+		})
+	}
+	var classified []error
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Parallel(failAfterBoth(errA), failAfterBoth(errB)),
+	),
+		retryflow.WithMaxRetries(1),
+		retryflow.WithErrorClassifier(func(err error) retryflow.ErrorClass {
+			classified = append(classified, err)
+			return retryflow.ClassTransient
+		}),
+	)
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Fatalf("expected both child errors, got %v", err)
+	}
+	if len(classified) != 2 {
+		t.Errorf("expected the classifier to see both child errors, got %v", classified)
+	}
+}
+
+func TestParallelRecoversChildPanic(t *testing.T) {
+	var siblingErr error
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Parallel(
+			retryflow.Exec(func(ctx context.Context) error { panic("boom") }),
+			retryflow.Exec(func(ctx context.Context) error {
+				<-ctx.Done()
+				siblingErr = ctx.Err()
+				return siblingErr
+			}),
+		),
+	),
+		retryflow.WithRecoverPanic(true),
+		retryflow.WithPanicClass(retryflow.ClassTransient),
+		retryflow.WithMaxRetries(1),
+	)
+	var pe *retryflow.PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected *PanicError, got %v", err)
+	}
+	if pe.Value != "boom" || pe.Class() != retryflow.ClassTransient || len(pe.Stack) == 0 {
+		t.Errorf("expected a transient panic with value boom and a stack, got %v, %v", pe.Value, pe.Class())
+	}
+	if !errors.Is(siblingErr, context.Canceled) {
+		t.Errorf("expected the sibling to be cancelled, got %v", siblingErr)
+	}
+}
+
+func TestParallelRejectsChildSettings(t *testing.T) {
+	noop := func(ctx context.Context) error { return nil }
+	tests := []struct {
+		child *retryflow.Step
+		want  string
+	}{
+		{retryflow.Exec(noop).Timeout(time.Second), "Timeout"},
+		{retryflow.Exec(noop).When(func(any) bool { return true }), "When"},
+		{retryflow.Exec(noop).Optional(), "Optional"},
+		{retryflow.Exec(noop).Retryable(func(error) bool { return true }), "Retryable"},
+		{retryflow.Exec(noop).Classify(func(error) retryflow.ErrorClass { return retryflow.ClassTransient }), "Classify"},
+		{retryflow.Exec(noop).Compensate(func(context.Context, any) error { return nil }), "Compensate"},
+		{retryflow.Exec(noop).Checkpoint(), "Checkpoint"},
+		{retryflow.Parallel(retryflow.Exec(noop).Checkpoint()), "Parallel child 1: Checkpoint"},
+	}
+	for _, tt := range tests {
+		ran := false
+		err := retryflow.Retry(context.Background(), retryflow.Seq(
+			retryflow.Exec(func(ctx context.Context) error { ran = true; return nil }),
+			retryflow.Parallel(retryflow.Exec(noop).Name("ok"), tt.child),
+		))
+		var cerr *retryflow.ConfigError
+		if !errors.As(err, &cerr) || !strings.Contains(err.Error(), "Parallel child 2: "+tt.want) {
+			t.Errorf("%s: expected a *ConfigError for Parallel child 2, got %v", tt.want, err)
+		}
+		if ran {
+			t.Errorf("%s: expected no step to run", tt.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package retryflow
+
+import (
+	"maps"
+	"sync/atomic"
+)
+
+// Policy is the scalar retry configuration as a plain value that platforms
+// can inspect, compare and enforce, e.g. rejecting any policy with
+// MaxRetries above 10. Build one with NewPolicy, adjust its fields and apply
+// it with WithPolicy. It has the fields of ConfigSnapshot.
+type Policy ConfigSnapshot
+
+// NewPolicy returns the policy resulting from applying opts over the
+// defaults. Function-valued options such as hooks are not retained.
+func NewPolicy(opts ...Option) Policy {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return Policy(o.snapshot())
+}
+
+// WithPolicy replaces every scalar setting with the fields of p, zero values
+// included. Options after it override single settings of p, and options
+// before it are overridden by p. The fields identifying a single flow,
+// FlowKey, FlowName, IdempotencyKey and Deadline, are not applied, so that
+// one policy can be shared by unrelated flows.
+func WithPolicy(p Policy) Option {
+	queued := new(atomic.Int64) // shared like the WithMaxQueuedRetries option
+	return func(o *options) {
+		o.initialBackoff = p.InitialBackoff
+		o.maxBackoff = p.MaxBackoff
+		o.jitter = p.Jitter
+		o.jitterFraction = p.JitterFraction
+		o.jitterMode = p.JitterMode
+		o.decorrelatedBase = p.DecorrelatedJitterBase
+		o.decorrelatedCap = p.DecorrelatedJitterCap
+		o.adaptiveJitter = p.AdaptiveJitter
+		o.jitterClasses = nil
+		if p.JitterClasses != nil {
+			WithJitterClasses(p.JitterClasses...)(o)
+		}
+		o.retryableClasses = nil
+		if p.RetryableClasses != nil {
+			o.retryable = nil // replaced like by WithRetryableClasses
+		}
+		for _, c := range p.RetryableClasses {
+			if o.retryableClasses == nil {
+				o.retryableClasses = make(map[ErrorClass]bool)
+			}
+			o.retryableClasses[c] = true
+		}
+		o.restartClasses = nil
+		for _, c := range p.RestartClasses {
+			if o.restartClasses == nil {
+				o.restartClasses = make(map[ErrorClass]bool)
+			}
+			o.restartClasses[c] = true
+		}
+		o.minBackoffByClass = maps.Clone(p.MinBackoffByClass)
+		o.loadFactor = p.LoadFactor
+		o.maxRetries = p.MaxRetries
+		o.maxElapsedTime = p.MaxElapsedTime
+		o.maxIterations = p.MaxIterations
+		o.maxQueued = p.MaxQueuedRetries
+		o.queued = queued
+		o.perErrorLimits = maps.Clone(p.PerErrorLimits)
+		o.maxDistinctClasses = p.MaxDistinctErrorClasses
+		o.multiErrorPolicy = p.MultiErrorPolicy
+		o.errorRateThreshold = p.ErrorRateThreshold
+		o.errorRateMinSamples = p.ErrorRateMinSamples
+		o.autoCheckpointEvery = p.AutoCheckpointEvery
+		o.checkpointCooldown = p.CheckpointCooldown
+		o.sameOutputStep = p.SameOutputStep
+		o.maxSameOutput = p.MaxSameOutput
+		o.wholeFlowRetry = p.WholeFlowRetry
+		o.compensateAll = p.CompensateAll
+		o.captureSteps = nil
+		if p.CaptureSteps != nil {
+			WithCaptureSteps(p.CaptureSteps...)(o)
+		}
+		o.maxEventHistory = p.MaxEventHistory
+		o.outputCoercion = p.OutputCoercion
+		o.strictValidation = p.StrictValidation
+		o.recoverPanic = p.RecoverPanic
+		o.panicClass = p.PanicClass
+		o.resetErrorLimitOnCheckpoint = p.ResetErrorLimitOnCheckpoint
+		o.resetBackoffOnClassChange = p.ResetBackoffOnClassChange
+		o.collapseRetries = p.CollapseRepeatedRetries
+	}
+}
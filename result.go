@@ -0,0 +1,37 @@
+package retryflow
+
+import (
+	"maps"
+	"time"
+)
+
+// RetryResult describes how far a flow run by RetryWithResult got.
+type RetryResult struct {
+	// Attempts is the number of attempts made, counted across checkpoints.
+	Attempts int
+	// LastStep is the 1-based index of the last step reached, whether it
+	// succeeded or failed.
+	LastStep int
+	// MaxStepReached is the 1-based index of the furthest step any attempt
+	// reached, showing where a flow gets stuck.
+	MaxStepReached int
+	// FinalFailedStep is the 1-based index of the step that failed on the
+	// last attempt, or 0 if the flow succeeded or the last attempt did not
+	// fail in a step, e.g. because its preflight check failed.
+	FinalFailedStep int
+	// Checkpoint is the 1-based index of the last checkpoint that committed,
+	// or 0 if none did. Outputs of steps up to it have been stored.
+	Checkpoint int
+	// Elapsed is the total time spent in the flow, backoff sleeps included.
+	Elapsed time.Duration
+
+	classCounts map[ErrorClass]int
+}
+
+// ClassCounts returns how many failures of each error class the flow saw.
+// With WithResetErrorLimitOnCheckpoint(true), the default, the counts start
+// over at each checkpoint like the per-error limits they drive, so they
+// cover the failures since the last checkpoint.
+func (r *RetryResult) ClassCounts() map[ErrorClass]int {
+	return maps.Clone(r.classCounts)
+}
@@ -0,0 +1,276 @@
+package retryflow_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Vealcoo/retryflow"
+)
+
+func TestRetryWithResultReportsCheckpoint(t *testing.T) {
+	errFail := errors.New("fail")
+	var first, second int
+	steps := retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (int, error) { return 1, nil }).Do(&first),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) { return in + 1, nil }).Do(&second).Checkpoint(),
+		retryflow.Exec(func(ctx context.Context) error { return errFail }),
+		retryflow.Exec(func(ctx context.Context) error { return nil }),
+	)
+
+	res, err := retryflow.RetryWithResult(context.Background(), steps,
+		retryflow.WithMaxRetries(3),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if !errors.Is(err, errFail) {
+		t.Fatalf("expected errFail, got %v", err)
+	}
+	// The checkpoint resets the attempt counter, so three retries follow the first attempt
+	if res.Checkpoint != 2 || res.LastStep != 3 || res.Attempts != 4 {
+		t.Errorf("expected checkpoint 2, last step 3 and 4 attempts, got %+v", *res)
+	}
+	if res.Elapsed <= 0 {
+		t.Errorf("expected positive elapsed time, got %v", res.Elapsed)
+	}
+	if first != 1 || second != 2 {
+		t.Errorf("expected committed outputs 1 and 2, got %d and %d", first, second)
+	}
+}
+
+func TestRetryWithResultSuccess(t *testing.T) {
+	calls := 0
+	res, err := retryflow.RetryWithResult(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return nil }),
+		retryflow.Exec(func(ctx context.Context) error {
+			calls++
+			if calls < 2 {
+				return errors.New("fail")
+			}
+			return nil
+		}),
+	),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if res.Attempts != 2 || res.LastStep != 2 || res.Checkpoint != 0 || res.FinalFailedStep != 0 {
+		t.Errorf("expected 2 attempts, last step 2, no checkpoint and no failed step, got %+v", *res)
+	}
+}
+
+func TestTypedResultMatchesDo(t *testing.T) {
+	type order struct {
+		ID    string
+		Total int
+	}
+	newSteps := func() retryflow.Steps {
+		return retryflow.Seq(
+			retryflow.Chain(func(ctx context.Context, _ any) (string, error) { return "o-1", nil }),
+			retryflow.Chain(func(ctx context.Context, id string) (order, error) { return order{id, 42}, nil }),
+		)
+	}
+
+	var viaDo order
+	withDo := newSteps()
+	withDo[1].Do(&viaDo)
+	if err := retryflow.Retry(context.Background(), withDo); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	result := retryflow.Result[order]()
+	if _, ok := result.Get(); ok {
+		t.Fatal("expected no value before the flow ran")
+	}
+	if err := retryflow.Retry(context.Background(), append(newSteps(), result.Step())); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	got, ok := result.Get()
+	if !ok || got != viaDo {
+		t.Errorf("expected %+v as with Do, got %+v (ok %v)", viaDo, got, ok)
+	}
+}
+
+func TestTypedResultTypeMismatch(t *testing.T) {
+	result := retryflow.Result[int]()
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (string, error) { return "x", nil }),
+		result.Step(),
+	), retryflow.WithMaxRetries(1))
+	if err == nil {
+		t.Fatal("expected a type mismatch error, got nil")
+	}
+	if _, ok := result.Get(); ok {
+		t.Error("expected no value after a mismatch")
+	}
+}
+
+func TestRetryResultClassCounts(t *testing.T) {
+	classes := []retryflow.ErrorClass{
+		retryflow.ClassTimeout, // before the checkpoint
+		retryflow.ClassRateLimit,
+		retryflow.ClassTimeout,
+		retryflow.ClassRateLimit,
+		retryflow.ClassAuth,
+		retryflow.ClassAuth,
+	}
+	failures := 0
+	passedFirst := false
+	steps := retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			if !passedFirst {
+				passedFirst = true
+				failures++
+				return errors.New("fail")
+			}
+			return nil
+		}).Checkpoint(),
+		retryflow.Exec(func(ctx context.Context) error {
+			failures++
+			return errors.New("fail")
+		}),
+	)
+	opts := func(reset bool) []retryflow.Option {
+		return []retryflow.Option{
+			retryflow.WithMaxRetries(4),
+			retryflow.WithInitialBackoff(time.Millisecond),
+			retryflow.WithJitter(0),
+			retryflow.WithResetErrorLimitOnCheckpoint(reset),
+			retryflow.WithErrorClassifier(func(error) retryflow.ErrorClass { return classes[failures-1] }),
+		}
+	}
+
+	res, err := retryflow.RetryWithResult(context.Background(), steps, opts(true)...)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	want := map[retryflow.ErrorClass]int{retryflow.ClassRateLimit: 2, retryflow.ClassTimeout: 1, retryflow.ClassAuth: 2}
+	if got := res.ClassCounts(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected counts since the checkpoint %v, got %v", want, got)
+	}
+
+	failures, passedFirst = 0, false
+	res, _ = retryflow.RetryWithResult(context.Background(), steps, opts(false)...)
+	want[retryflow.ClassTimeout] = 2
+	if got := res.ClassCounts(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected counts across the flow %v, got %v", want, got)
+	}
+}
+
+func TestRetryResultFailedSteps(t *testing.T) {
+	tests := []struct {
+		name      string
+		failAt    []int // step failing on each attempt
+		wantFinal int
+	}{
+		{"FinalAttemptFurthest", []int{2, 4, 4}, 4},
+		{"FinalAttemptEarlier", []int{4, 3, 2}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempt := 0
+			var steps retryflow.Steps
+			for i := 1; i <= 5; i++ {
+				steps = append(steps, retryflow.Exec(func(ctx context.Context) error {
+					if i == 1 {
+						attempt++
+					}
+					if i == tt.failAt[attempt-1] {
+						return fmt.Errorf("step %d failed", i)
+					}
+					return nil
+				}))
+			}
+
+			res, err := retryflow.RetryWithResult(context.Background(), steps,
+				retryflow.WithMaxRetries(len(tt.failAt)),
+				retryflow.WithInitialBackoff(time.Millisecond),
+				retryflow.WithJitter(0),
+			)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if res.FinalFailedStep != tt.wantFinal || res.MaxStepReached != 4 {
+				t.Errorf("expected final failed step %d and max step 4, got %d and %d",
+					tt.wantFinal, res.FinalFailedStep, res.MaxStepReached)
+			}
+		})
+	}
+}
+
+func TestResultsGetTyped(t *testing.T) {
+	type order struct{ ID int }
+	var results retryflow.Results
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (order, error) { return order{ID: 42}, nil }).Name("order"),
+		retryflow.Chain(func(ctx context.Context, o order) (string, error) {
+			return fmt.Sprintf("receipt-%d", o.ID), nil
+		}).Name("receipt"),
+		retryflow.Exec(func(ctx context.Context) error { return nil }),
+	), retryflow.WithResults(&results))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	o, err := retryflow.GetTyped[order](&results, "order")
+	if err != nil || o.ID != 42 {
+		t.Errorf("expected order 42, got %+v, %v", o, err)
+	}
+	receipt, err := retryflow.GetTyped[string](&results, "receipt")
+	if err != nil || receipt != "receipt-42" {
+		t.Errorf("expected receipt-42, got %q, %v", receipt, err)
+	}
+	if _, err := retryflow.GetTyped[int](&results, "receipt"); err == nil {
+		t.Error("expected a type mismatch error")
+	}
+	if _, err := retryflow.GetTyped[string](&results, "missing"); err == nil {
+		t.Error("expected an error for an unknown step")
+	}
+}
+
+func TestRetryWithResultFlowKeyCopies(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var calls atomic.Int32
+	newSteps := func() retryflow.Steps {
+		return retryflow.Seq(retryflow.Exec(func(ctx context.Context) error {
+			if calls.Add(1) == 1 {
+				close(started)
+				<-release
+				return errors.New("fail")
+			}
+			return nil
+		}))
+	}
+	opts := []retryflow.Option{
+		retryflow.WithFlowKey("shared"),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithErrorClassifier(func(error) retryflow.ErrorClass { return retryflow.ClassTransient }),
+	}
+
+	results := make(chan *retryflow.RetryResult, 2)
+	go func() { res, _ := retryflow.RetryWithResult(context.Background(), newSteps(), opts...); results <- res }()
+	<-started
+	go func() { res, _ := retryflow.RetryWithResult(context.Background(), newSteps(), opts...); results <- res }()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	a, b := <-results, <-results
+	if a == b {
+		t.Fatal("expected each caller to get its own result")
+	}
+	if a.Attempts != 2 || b.Attempts != 2 || a.ClassCounts()[retryflow.ClassTransient] != 1 {
+		t.Errorf("expected both copies to describe the shared run, got %+v and %+v", *a, *b)
+	}
+	a.Attempts = 99
+	if b.Attempts != 2 {
+		t.Error("expected the copies to be independent")
+	}
+}
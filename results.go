@@ -0,0 +1,50 @@
+package retryflow
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Results keeps the latest output of each step named with Step.Name, so
+// outputs can be read after the run without wiring a Do pointer to every
+// step. Pass a pointer with WithResults. The zero value is ready to use.
+type Results struct {
+	mu      sync.Mutex
+	outputs map[string]any
+}
+
+// Get returns the latest output of the step named name and whether it
+// succeeded.
+func (r *Results) Get(name string) (any, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.outputs[name]
+	return v, ok
+}
+
+func (r *Results) set(name string, output any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.outputs == nil {
+		r.outputs = make(map[string]any)
+	}
+	r.outputs[name] = output
+}
+
+// GetTyped returns the latest output of the step named name as a T. It
+// fails if the step has not succeeded or its output is not a T.
+func GetTyped[T any](r *Results, name string) (T, error) {
+	var zero T
+	v, ok := r.Get(name)
+	if !ok {
+		return zero, fmt.Errorf("no output for step %q", name)
+	}
+	if v == nil {
+		return zero, nil
+	}
+	t, ok := v.(T)
+	if !ok {
+		return zero, fmt.Errorf("output of step %q is %T, not %T", name, v, zero)
+	}
+	return t, nil
+}
@@ -4,17 +4,101 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"math/rand"
+	"maps"
 	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"golang.org/x/sync/singleflight"
 )
 
 // Retry executes the sequence of steps with retry logic.
 func Retry(ctx context.Context, steps Steps, opts ...Option) error {
+	_, err := RetryWithResult(ctx, steps, opts...)
+	return err
+}
+
+// RetryWithResult is like Retry but also reports the attempts made, the
+// last step reached and the last checkpoint committed, on success and on
+// failure alike.
+func RetryWithResult(ctx context.Context, steps Steps, opts ...Option) (*RetryResult, error) {
 	if len(steps) == 0 {
-		return nil
+		return &RetryResult{}, nil
+	}
+
+	o, err := buildOptions(steps, opts)
+	if err != nil {
+		return nil, err
+	}
+	if o.flowKey != "" {
+		shared := joinFlow(ctx, o.flowKey)
+		defer shared.leave()
+		ch := flows.DoChan(o.flowKey, func() (any, error) {
+			res := &RetryResult{}
+			return res, run(shared.ctx, steps, &o, &flowState{result: res})
+		})
+		select {
+		case r := <-ch:
+			// Every caller sharing the run gets its own copy
+			res := *r.Val.(*RetryResult)
+			res.classCounts = maps.Clone(res.classCounts)
+			return &res, r.Err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	res := &RetryResult{}
+	return res, run(ctx, steps, &o, &flowState{result: res})
+}
+
+// flows deduplicates concurrent Retry calls sharing a WithFlowKey key.
+var flows singleflight.Group
+
+// sharedFlow is the context of the flow run for a WithFlowKey key. It is
+// detached from the cancellation of the caller that started it and is
+// cancelled once every caller waiting for the flow has left.
+type sharedFlow struct {
+	key     string
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int
+}
+
+var (
+	sharedFlowsMu sync.Mutex
+	sharedFlows   = make(map[string]*sharedFlow)
+)
+
+// joinFlow registers a caller waiting for the flow with key, creating the
+// flow context from ctx for the first one.
+func joinFlow(ctx context.Context, key string) *sharedFlow {
+	sharedFlowsMu.Lock()
+	defer sharedFlowsMu.Unlock()
+	f := sharedFlows[key]
+	if f == nil {
+		f = &sharedFlow{key: key}
+		f.ctx, f.cancel = context.WithCancel(context.WithoutCancel(ctx))
+		sharedFlows[key] = f
 	}
+	f.waiters++
+	return f
+}
+
+// leave unregisters a waiting caller, cancelling the flow after the last one.
+func (f *sharedFlow) leave() {
+	sharedFlowsMu.Lock()
+	defer sharedFlowsMu.Unlock()
+	if f.waiters--; f.waiters == 0 {
+		f.cancel()
+		delete(sharedFlows, f.key)
+	}
+}
 
+// buildOptions applies opts over the defaults and validates the result
+// for steps.
+func buildOptions(steps Steps, opts []Option) (options, error) {
 	o := defaultOptions()
 	for _, opt := range opts {
 		opt(&o)
@@ -22,143 +106,683 @@ func Retry(ctx context.Context, steps Steps, opts ...Option) error {
 
 	// Validate options
 	if o.initialBackoff <= 0 {
-		return errors.New("initialBackoff must be positive")
+		return o, &ConfigError{Field: "initialBackoff", Err: errors.New("initialBackoff must be positive")}
 	}
 	if o.maxBackoff < o.initialBackoff {
-		return errors.New("maxBackoff must be >= initialBackoff")
+		return o, &ConfigError{Field: "maxBackoff", Err: errors.New("maxBackoff must be >= initialBackoff")}
 	}
 	if o.jitter < 0 {
-		return errors.New("jitter must be non-negative")
+		return o, &ConfigError{Field: "jitter", Err: errors.New("jitter must be non-negative")}
+	}
+	if o.jitterFraction < 0 || o.jitterFraction > 1 {
+		return o, &ConfigError{Field: "jitterFraction", Err: errors.New("jitterFraction must be between 0 and 1")}
+	}
+	if o.maxRetries < 0 && o.maxElapsedTime == 0 && o.deadline.IsZero() {
+		return o, &ConfigError{Field: "maxElapsedTime", Err: errors.New("infinite retry without maxElapsedTime is dangerous")}
 	}
-	if o.maxRetries < 0 && o.maxElapsedTime == 0 {
-		return errors.New("infinite retry without maxElapsedTime is dangerous")
+	if err := steps.checkParallel(); err != nil {
+		return o, &ConfigError{Field: "steps", Err: err}
 	}
+	labels, err := steps.labels()
+	if err != nil {
+		return o, &ConfigError{Field: "steps", Err: err}
+	}
+	o.labels = labels
+	for _, w := range o.warnings(steps) {
+		if o.strictValidation {
+			return o, w
+		}
+		o.warn(w)
+	}
+	return o, nil
+}
+
+// flowState carries the resume position of a flow between runs.
+type flowState struct {
+	checkpoint       int // number of steps committed by the last checkpoint
+	checkpointOutput any
+	// pause makes run return as soon as a checkpoint before the last step commits
+	pause     bool
+	paused    bool
+	committed []completed  // compensable steps committed by a checkpoint, for WithCompensateAll
+	store     *sync.Map    // backs FlowStore
+	key       string       // returned by IdempotencyKeyFromContext
+	result    *RetryResult // filled in when run returns, if set
+}
 
+// run executes steps from state's checkpoint until they all succeed, the
+// flow gives up, or state.pause is set and a checkpoint commits.
+func run(ctx context.Context, steps Steps, o *options, state *flowState) (err error) {
 	// Initialize checkpoint and attempt counter
 	var checkpoint int
 	var currentAttempt int
+	var totalAttempts int
+
+	if o.observer != nil {
+		ctx = o.observer.StartFlow(ctx, o.flowName)
+		flowCtx := ctx
+		defer func() { o.observer.EndFlow(flowCtx, err) }()
+	}
+	if o.canceler != nil {
+		var detach func()
+		ctx, detach = o.canceler.attach(ctx)
+		defer detach()
+	}
+
+	if err := o.loadCheckpoint(ctx, steps, state); err != nil {
+		return err
+	}
 
 	currentBackoff := o.initialBackoff
-	start := time.Now()
-	checkpoint = 0                                                    // Reset checkpoint at start
+	backoffOffset := 0 // attempts before the backoff last started over, so strategies see attempt 1 again
+	start := o.clock.Now()
+	// The deadline is converted once into a duration, so that all budget
+	// checks compare monotonic elapsed times and survive wall clock jumps
+	budget := o.maxElapsedTime
+	if !o.deadline.IsZero() {
+		left := o.deadline.Sub(start)
+		if left <= 0 {
+			return context.DeadlineExceeded
+		}
+		if budget <= 0 || left < budget {
+			budget = left
+		}
+	}
+	// Steps run under the budget too, so that a slow step is cancelled
+	// when it runs out instead of only being noticed after it fails. Context
+	// deadlines follow the real clock, so an injected clock only enforces
+	// the budget between attempts, where elapsed time is measured on it
+	parent := ctx
+	if _, ok := o.clock.(realClock); ok && budget > 0 {
+		var cancelBudget context.CancelFunc
+		ctx, cancelBudget = context.WithTimeout(ctx, budget)
+		defer cancelBudget()
+	}
+	budgetExpired := func() bool { return ctx.Err() != nil && parent.Err() == nil }
+	checkpoint = state.checkpoint                                     // Resume from the saved checkpoint
 	currentAttempt = 0                                                // Reset attempt counter at start
 	perErrorCounts := make(map[ErrorClass]int, len(o.perErrorLimits)) // Reset error counts at start
+	stepRuns := make(map[int]int)                                     // Runs of each step by 1-based index, for MaxAttempts
+	var sameOutput any                                                // Latest output of the WithMaxSameOutput step
+	sameOutputs := -1                                                 // Times in a row it repeated, -1 before its first output
+	seenClasses := make(map[ErrorClass]bool)                          // Distinct classes seen across the flow
+	var prevClass ErrorClass                                          // Class of the previous failure
+	classStreak := 0                                                  // Consecutive failures of prevClass, for WithAdaptiveJitter
+	prevFailedStep := 0                                               // Step whose failure caused the last retry
+	restartedFrom := 0                                                // Checkpoint discarded by WithRestartClasses, until regained
+
+	if state.store == nil {
+		state.store = new(sync.Map)
+	}
+	if state.key == "" {
+		state.key = o.idempotencyKey
+		if state.key == "" {
+			state.key = newUUID()
+		}
+	}
+	lastStep := 0
+	maxStepReached := 0
+	finalFailedStep := 0 // step that failed on the latest attempt
+	preflightPassed := false
+	var stop atomic.Bool        // set by StopRetrying
+	var uncommitted []completed // compensable steps that succeeded since the last checkpoint
+	defer func() {
+		if err == nil {
+			return
+		}
+		if o.compensateAll {
+			uncommitted = append(state.committed, uncommitted...)
+		}
+		err = o.compensate(ctx, steps, uncommitted, err)
+	}()
+	defer func() {
+		if r := state.result; r != nil {
+			r.Attempts = totalAttempts
+			r.LastStep = lastStep
+			r.MaxStepReached = maxStepReached
+			if err != nil {
+				r.FinalFailedStep = finalFailedStep
+			}
+			r.Checkpoint = checkpoint
+			r.Elapsed = o.clock.Now().Sub(start)
+			r.classCounts = maps.Clone(perErrorCounts)
+		}
+	}()
+	cancelAttempt := context.CancelFunc(func() {})
+	defer func() { cancelAttempt() }()
+	var observedAttempt context.Context // context of the attempt open with the observer
+	var attemptClass ErrorClass         // class of the failure ending the attempt, once classified
+	endAttempt := func(err error, class ErrorClass, backoff time.Duration) {
+		if observedAttempt != nil {
+			o.observer.EndAttempt(observedAttempt, err, class, backoff)
+			observedAttempt = nil
+		}
+	}
+	defer func() {
+		if observedAttempt != nil && err != nil && attemptClass == "" {
+			attemptClass, _ = o.classify(fullUnwrap(err), nil)
+		}
+		endAttempt(err, attemptClass, 0)
+	}()
+	inputs := make([]any, len(steps)) // last input of each step, for RetryFrom
+	outputs := make(map[int]any)      // last output of each step, for onStepOutputDiff
+	resumeIdx := -1                   // step to resume from instead of the checkpoint
 
 	var prevOutput any
-	var lastCheckpointOutput any = nil
+	var lastCheckpointOutput any = state.checkpointOutput
+	// restart discards the checkpoint, so that the next attempt starts again
+	// from the first step
+	restart := func() error {
+		resumeIdx = -1
+		checkpoint = 0
+		lastCheckpointOutput = nil
+		state.committed = nil
+		state.checkpoint = 0
+		state.checkpointOutput = nil
+		return o.saveCheckpoint(ctx, 0, nil)
+	}
+	if o.stats != nil {
+		*o.stats = Stats{
+			AttemptsToSuccess: -1,
+			LastErrorByClass:  make(map[ErrorClass]error),
+			Outputs:           make(map[int]any),
+		}
+	}
+
+	if o.onStart != nil {
+		o.onStart(o.snapshot())
+	}
+
+	giveUp := func(reason GiveUpReason, err error) error {
+		if o.logger != nil {
+			class := attemptClass
+			if class == "" {
+				class, _ = o.classify(fullUnwrap(err), nil)
+			}
+			o.logger.Warn("giving up", append(o.logFields(currentAttempt, err, class), "reason", reason.String())...)
+		}
+		if o.onGiveUp != nil {
+			o.onGiveUp(currentAttempt, err, reason)
+		}
+		return err
+	}
+	// interrupted returns err stopping the flow between steps. Once an
+	// attempt has failed, the flow gives up with err and the last failure
+	var lastErr error
+	interrupted := func(err error) error {
+		if lastErr == nil {
+			return err
+		}
+		if budgetExpired() {
+			return giveUp(GiveUpMaxElapsedTime, lastErr)
+		}
+		return giveUp(GiveUpCanceled, fmt.Errorf("%w: %w", err, lastErr))
+	}
+
+	// Under collapseRetries, retries are reported once per streak of
+	// failures with the same class and root message
+	var streak *RepeatedError
+	var streakAttempt int
+	var streakMsg string
+	flushRetries := func() {
+		if streak != nil {
+			o.onRetry(streakAttempt, streak)
+			streak = nil
+		}
+	}
+	defer flushRetries()
+
 	for {
 		currentAttempt += 1
+		totalAttempts += 1
 		prevOutput = lastCheckpointOutput
+		startIdx := checkpoint // 0-based
+		// A label before the checkpoint, possibly one loaded from a store
+		// whose inputs this run never saw, falls back to the checkpoint
+		if resumeIdx >= 0 && resumeIdx >= checkpoint {
+			startIdx = resumeIdx
+			prevOutput = inputs[resumeIdx]
+		}
+		resumeIdx = -1
+		// Steps from startIdx on run again
+		uncommitted = slices.DeleteFunc(uncommitted, func(c completed) bool { return c.step > startIdx })
+
+		if o.circuitBreaker != nil && !o.circuitBreaker.Allow() {
+			if totalAttempts > 1 {
+				return giveUp(GiveUpCircuitOpen, ErrCircuitOpen)
+			}
+			return ErrCircuitOpen
+		}
 
 		// Apply rate limiter if present
 		if o.rateLimiter != nil {
-			if err := o.rateLimiter.Wait(ctx); err != nil {
-				return err
+			if err := o.waitLimiter(ctx); err != nil {
+				return interrupted(err)
 			}
 		}
 
+		if o.logger != nil {
+			o.logger.Debug("attempt started", "flow", o.flowName, "attempt", currentAttempt, "step", startIdx+1)
+		}
 		if o.onAttemptStart != nil {
 			o.onAttemptStart(currentAttempt)
 		}
+		if o.stats != nil {
+			history := o.stats.ExecutedSteps
+			if o.maxEventHistory > 0 && len(history) >= o.maxEventHistory {
+				// Drop the oldest attempts, reusing the backing array
+				n := copy(history, history[len(history)-o.maxEventHistory+1:])
+				clear(history[n:])
+				history = history[:n]
+			}
+			o.stats.ExecutedSteps = append(history, nil)
+		}
+
+		remaining := -1
+		if o.maxRetries >= 0 {
+			remaining = max(o.maxRetries-currentAttempt, 0)
+		}
+		// Each attempt gets its own context, cancelled when the attempt ends
+		attemptCtx, cancel := context.WithCancel(context.WithValue(ctx, attemptKey{}, &attemptInfo{
+			attempt:          currentAttempt,
+			remainingRetries: remaining,
+			start:            start,
+			maxElapsedTime:   budget,
+			clock:            o.clock,
+			store:            state.store,
+			idempotencyKey:   state.key,
+			stop:             &stop,
+
+			errorRateThreshold:  o.errorRateThreshold,
+			errorRateMinSamples: o.errorRateMinSamples,
+		}))
+		cancelAttempt = cancel
+		attemptClass = ""
+		if o.observer != nil {
+			attemptCtx = o.observer.StartAttempt(attemptCtx, currentAttempt)
+			observedAttempt = attemptCtx
+		}
 
 		var err error
+		var failedStep *Step
 		failed := false
-		startIdx := checkpoint // 0-based
+		finalFailedStep = 0
+
+		if o.preflight != nil && !preflightPassed {
+			if perr := o.preflight(attemptCtx); perr != nil {
+				failed = true
+				err = &AttemptError{Attempt: currentAttempt, Step: 0, Err: perr}
+			} else {
+				preflightPassed = true
+			}
+		}
 
-		for i := startIdx; i < len(steps); i++ {
+		for i := startIdx; !failed && i < len(steps); i++ {
 			if ctx.Err() != nil {
+				if budgetExpired() {
+					return giveUp(GiveUpMaxElapsedTime, ctx.Err())
+				}
+				if totalAttempts > 1 {
+					return giveUp(GiveUpCanceled, ctx.Err())
+				}
 				return ctx.Err()
 			}
 
 			step := steps[i]
+			lastStep = i + 1
+			maxStepReached = max(maxStepReached, lastStep)
+
+			inputs[i] = prevOutput
+			input := prevOutput
+			if o.outputCoercion && step.inType != nil {
+				if v, ok := coerce(input, step.inType); ok {
+					input = v
+				}
+			}
+
+			if step.when != nil && !step.when(input) {
+				o.skip(i+1, ReasonPredicate)
+				continue
+			}
+			if budget, ok := RemainingBudget(attemptCtx); ok && budget < step.minBudget {
+				o.skip(i+1, ReasonBudget)
+				continue
+			}
+
+			stepCtx, cancelStep := context.WithValue(attemptCtx, stepKey{}, i+1), context.CancelFunc(func() {})
+			if budget, ok := stepBudget(attemptCtx); ok && step.budgetFrac > 0 {
+				stepCtx, cancelStep = context.WithTimeout(stepCtx, time.Duration(float64(budget)*step.budgetFrac))
+			}
+
+			if step.timeout > 0 {
+				var cancelTimeout context.CancelFunc
+				stepCtx, cancelTimeout = context.WithTimeout(stepCtx, step.timeout)
+				cancelBudget := cancelStep
+				cancelStep = func() { cancelTimeout(); cancelBudget() }
+			}
 
+			if o.stats != nil {
+				n := len(o.stats.ExecutedSteps) - 1
+				o.stats.ExecutedSteps[n] = append(o.stats.ExecutedSteps[n], i+1)
+			}
+
+			stepRuns[i+1]++
+			if o.onStepStart != nil {
+				o.onStepStart(i+1, input)
+			}
+			if o.observer != nil {
+				stepCtx = o.observer.StartStep(stepCtx, i+1, step.name)
+			}
 			var output any
-			output, err = step.run(ctx, prevOutput)
+			if o.recoverPanic {
+				output, err = runRecovered(stepCtx, step, input, o.panicClass)
+			} else {
+				output, err = step.run(stepCtx, input)
+			}
+			if err != nil && step.timeout > 0 && stepCtx.Err() == context.DeadlineExceeded &&
+				ctx.Err() == nil && !errors.Is(err, context.DeadlineExceeded) {
+				err = fmt.Errorf("step timed out after %v: %w: %w", step.timeout, context.DeadlineExceeded, err)
+			}
+			if o.observer != nil {
+				o.observer.EndStep(stepCtx, err)
+			}
+			cancelStep()
+			if err != nil && step.optional {
+				if step.onFail != nil {
+					step.onFail()
+				}
+				err = nil
+				o.skip(i+1, ReasonOptionalFailure)
+				continue
+			}
 			if err != nil {
 				failed = true
-				err = &AttemptError{Attempt: currentAttempt, Step: i + 1, Err: err}
+				failedStep = step
+				finalFailedStep = i + 1
+				err = &AttemptError{Attempt: currentAttempt, Step: i + 1, StepName: step.name, Err: err}
 				if step.onFail != nil {
 					step.onFail()
 				}
+				if step.retryFrom != "" && !o.wholeFlowRetry {
+					resumeIdx = o.labels[step.retryFrom]
+				}
 				break
 			}
 
-			// Store output if outputPtr is provided
-			if step.outputPtr != nil {
-				ptrVal := reflect.ValueOf(step.outputPtr)
-				if ptrVal.Kind() != reflect.Ptr || ptrVal.IsNil() {
-					return errors.New("outputPtr must be a non-nil pointer")
-				}
-				outType := ptrVal.Elem().Type()
-				if output != nil && !reflect.TypeOf(output).AssignableTo(outType) {
-					return fmt.Errorf("output type mismatch: expected %s, got %T", outType, output)
-				}
-				ptrVal.Elem().Set(reflect.ValueOf(output))
+			if err := step.deliver(output, o.outputCoercion); err != nil {
+				return err
+			}
+			if i+1 == prevFailedStep {
+				classStreak = 0
+			}
+			if o.results != nil && step.name != "" {
+				o.results.set(step.name, output)
+			}
+			if step.compensate != nil {
+				uncommitted = append(uncommitted, completed{step: i + 1, output: output})
 			}
 			// if step success, rewrite the previous output even the new output is nil
 			prevOutput = output
+			if o.stats != nil && (o.captureSteps == nil || o.captureSteps[i+1]) {
+				o.stats.Outputs[i+1] = output
+			}
+
+			if t, ok := o.rateLimiter.(throttler); ok {
+				t.Restore()
+			}
+			if o.circuitBreaker != nil {
+				o.circuitBreaker.RecordSuccess()
+			}
 
+			if o.logger != nil {
+				o.logger.Debug("step succeeded", "flow", o.flowName, "attempt", currentAttempt, "step", i+1)
+			}
 			if o.onStepSuccess != nil {
 				o.onStepSuccess(i+1, output)
 			}
+			if o.onNamedStepSuccess != nil {
+				o.onNamedStepSuccess(i+1, step.name, output)
+			}
+			if o.onStepOutputDiff != nil {
+				if prev, ok := outputs[i]; ok && !reflect.DeepEqual(prev, output) {
+					o.onStepOutputDiff(i+1, prev, output)
+				}
+				outputs[i] = output
+			}
+			if o.maxSameOutput > 0 && i+1 == o.sameOutputStep {
+				if sameOutputs >= 0 && reflect.DeepEqual(sameOutput, output) {
+					sameOutputs++
+				} else {
+					sameOutputs = 0
+				}
+				sameOutput = output
+				if sameOutputs >= o.maxSameOutput {
+					return giveUp(GiveUpStuck, &StuckError{Step: i + 1, Repeats: sameOutputs, Output: output})
+				}
+			}
 
-			if step.checkpoint {
+			if o.isCheckpoint(step, i) {
 				checkpoint = i + 1
-				currentAttempt = 0
 				lastCheckpointOutput = output
-				currentBackoff = o.initialBackoff
-				if o.resetErrorLimitOnCheckpoint {
-					perErrorCounts = make(map[ErrorClass]int, len(o.perErrorLimits))
+				// Regaining a checkpoint that WithRestartClasses discarded is
+				// no progress, so the attempt and error counts carry on
+				if checkpoint > restartedFrom {
+					restartedFrom = 0
+					currentAttempt = 0
+					currentBackoff = o.initialBackoff
+					backoffOffset = 0
+					if o.resetErrorLimitOnCheckpoint {
+						perErrorCounts = make(map[ErrorClass]int, len(o.perErrorLimits))
+					}
+				}
+				// Committed steps start over if a restart sends the flow back
+				// to them; steps after the checkpoint keep their counts
+				for idx := range stepRuns {
+					if idx <= checkpoint {
+						delete(stepRuns, idx)
+					}
+				}
+				state.checkpoint = checkpoint
+				state.checkpointOutput = output
+				state.committed = append(state.committed, uncommitted...)
+				uncommitted = nil
+				if err := o.saveCheckpoint(ctx, checkpoint, output); err != nil {
+					return err
+				}
+				if o.logger != nil {
+					o.logger.Info("checkpoint reached", "flow", o.flowName, "step", checkpoint)
+				}
+				if o.onCheckpoint != nil {
+					o.onCheckpoint(checkpoint, output)
+				}
+				if state.pause && checkpoint < len(steps) {
+					state.paused = true
+					return nil
+				}
+				if o.checkpointCooldown > 0 && checkpoint < len(steps) {
+					if err := o.clock.Sleep(ctx, o.checkpointCooldown); err != nil {
+						return interrupted(err)
+					}
 				}
 			}
 		}
 
+		cancelAttempt()
+
 		if !failed {
+			if o.stats != nil {
+				o.stats.AttemptsToSuccess = totalAttempts
+			}
+			state.paused = false
 			return nil
 		}
 
-		// Check if retryable
+		// Classified once, with the failed step's Classify, for the checks,
+		// the logs and the observer alike
 		unwrappedErr := fullUnwrap(err)
-		if !o.retryable(unwrappedErr) {
-			return err
+		key, multi := o.classify(unwrappedErr, failedStep)
+		attemptClass = key
+
+		if stop.Load() {
+			return giveUp(GiveUpStopped, err)
+		}
+
+		// Abort batches whose failure rate suggests the dependency is down
+		var batchErr *BatchError
+		if o.errorRateThreshold > 0 && errors.As(err, &batchErr) &&
+			batchErr.Total-batchErr.Skipped >= o.errorRateMinSamples && batchErr.FailureRate() > o.errorRateThreshold {
+			return giveUp(GiveUpErrorRate, err)
+		}
+
+		// Check if retryable
+		var retry bool
+		if errors.Is(err, ErrStaleCheckpoint) {
+			// Always retry, from the first step
+			retry = true
+			if err := restart(); err != nil {
+				return err
+			}
+		} else if failedStep != nil && failedStep.retryable != nil {
+			retry = failedStep.retryable(unwrappedErr)
+		} else if panicErr, ok := unwrappedErr.(*PanicError); ok && o.retryablePanic != nil {
+			retry = o.retryablePanic(panicErr.Value)
+		} else {
+			retry = o.isRetryable(err, failedStep)
+		}
+		if !retry {
+			return giveUp(GiveUpNonRetryable, err)
+		}
+		if o.circuitBreaker != nil {
+			o.circuitBreaker.RecordFailure()
 		}
 
 		// Check per-error limits
-		key := o.errorClassifier(unwrappedErr)
+		if multi && o.multiErrorPolicy == MultiErrorAnyPermanent && key == ClassPermanent {
+			return giveUp(GiveUpNonRetryable, err)
+		}
+		if o.resetBackoffOnClassChange && prevClass != "" && key != prevClass {
+			currentBackoff = o.initialBackoff
+			backoffOffset = currentAttempt - 1
+		}
+		if key != prevClass {
+			classStreak = 0
+		}
+		classStreak++
+		prevClass = key
+		prevFailedStep = finalFailedStep
 		perErrorCounts[key]++
+		if t, ok := o.rateLimiter.(throttler); ok && key == ClassRateLimit {
+			t.Throttle()
+		}
+		if o.stats != nil {
+			o.stats.LastErrorByClass[key] = err
+		}
 		if limit, ok := o.perErrorLimits[key]; ok && perErrorCounts[key] > limit {
-			return err
+			return giveUp(GiveUpErrorLimit, err)
+		}
+		seenClasses[key] = true
+		if o.maxDistinctClasses > 0 && len(seenClasses) > o.maxDistinctClasses {
+			return giveUp(GiveUpErrorLimit, err)
 		}
 
-		if o.onRetry != nil {
+		if failedStep != nil && failedStep.maxAttempts > 0 && stepRuns[lastStep] >= failedStep.maxAttempts {
+			return giveUp(GiveUpStepExhausted, &StepExhaustedError{Step: lastStep, Attempts: stepRuns[lastStep], Err: err})
+		}
+
+		if o.restartClasses[key] && checkpoint > 0 {
+			restartedFrom = max(restartedFrom, checkpoint)
+			if err := restart(); err != nil {
+				return err
+			}
+		}
+
+		if o.onRetry != nil && o.collapseRetries {
+			if msg := unwrappedErr.Error(); streak == nil || streak.Class != key || streakMsg != msg {
+				flushRetries()
+				streak = &RepeatedError{Class: key}
+				streakMsg = msg
+			}
+			streak.Count++
+			streak.Err = err
+			streakAttempt = currentAttempt
+		} else if o.onRetry != nil {
 			o.onRetry(currentAttempt, err)
 		}
 
 		if o.maxRetries >= 0 && currentAttempt >= o.maxRetries {
-			return err
+			return giveUp(GiveUpMaxRetries, err)
 		}
-		if o.maxElapsedTime > 0 && time.Since(start) >= o.maxElapsedTime {
-			return err
+		if budget > 0 && o.clock.Now().Sub(start) >= budget {
+			return giveUp(GiveUpMaxElapsedTime, err)
+		}
+
+		if o.maxIterations > 0 && totalAttempts >= o.maxIterations {
+			return giveUp(GiveUpMaxIterations, &LoopDetectedError{Iterations: totalAttempts, Err: err})
 		}
 
-		next := o.backoffStrategy(currentAttempt, currentBackoff)
-		next = min(next, o.maxBackoff)
+		next, sleep := o.backoff(currentAttempt-backoffOffset, o.clock.Now().Sub(start), currentBackoff, key, failedStep, classStreak)
 
-		sleep := next
-		if o.jitter > 0 {
-			j := time.Duration(rand.Int63n(int64(o.jitter*2))) - o.jitter
-			sleep += j
-			if sleep < 10*time.Millisecond {
-				sleep = 10 * time.Millisecond
+		if o.scheduleGuard != nil {
+			allow, delay := o.scheduleGuard(o.clock.Now())
+			if !allow {
+				return giveUp(GiveUpSchedule, err)
 			}
+			sleep += delay
 		}
 
-		select {
-		case <-time.After(sleep):
-		case <-ctx.Done():
-			return ctx.Err()
+		// Waking up after the deadline would only return ctx.Err()
+		if deadline, ok := parent.Deadline(); ok && !o.clock.Now().Add(sleep).Before(deadline) {
+			return giveUp(GiveUpDeadline, err)
+		}
+
+		// Taken last, so that a flow giving up for another reason keeps the token
+		if o.retryBudget != nil && !o.retryBudget.take() {
+			return giveUp(GiveUpBudget, fmt.Errorf("%w: %w", ErrBudgetExhausted, err))
+		}
+
+		if o.logger != nil {
+			o.logger.Info("retrying", append(o.logFields(currentAttempt, err, key), "backoff", sleep)...)
+		}
+		endAttempt(err, key, sleep)
+		lastErr = err
+
+		if serr := o.sleep(ctx, sleep); serr != nil {
+			if budgetExpired() {
+				return giveUp(GiveUpMaxElapsedTime, err)
+			}
+			return giveUp(GiveUpCanceled, serr)
 		}
 
 		currentBackoff = next
 	}
 }
+
+// skip reports a skipped step to the onStepSkip hook.
+func (o *options) skip(step int, reason SkipReason) {
+	if o.onStepSkip != nil {
+		o.onStepSkip(step, reason)
+	}
+}
+
+// isCheckpoint reports whether the step at 0-based index i commits a checkpoint.
+func (o *options) isCheckpoint(step *Step, i int) bool {
+	if o.wholeFlowRetry {
+		return false
+	}
+	return step.checkpoint || (o.autoCheckpointEvery > 0 && (i+1)%o.autoCheckpointEvery == 0)
+}
+
+// storeOutput sets dst to v. A nil v stores the zero value, which leaves
+// interface, pointer, map and slice targets nil.
+func storeOutput(dst reflect.Value, v any) error {
+	if v == nil {
+		dst.SetZero()
+		return nil
+	}
+	val := reflect.ValueOf(v)
+	if !val.Type().AssignableTo(dst.Type()) {
+		return fmt.Errorf("output type mismatch: expected %s, got %T", dst.Type(), v)
+	}
+	dst.Set(val)
+	return nil
+}
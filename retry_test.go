@@ -4,12 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"slices"
 	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/Vealcoo/retryflow"
+	"github.com/Vealcoo/retryflow/retryflowtest"
 	"golang.org/x/time/rate"
 )
 
@@ -173,8 +176,10 @@ func TestJitter(t *testing.T) {
 		}).Do(new(int)),
 	)
 
-	start := time.Now()
+	clock := retryflowtest.NewClock(time.Now())
+	start := clock.Now()
 	err := retryflow.Retry(ctx, steps,
+		retryflow.WithClock(clock),
 		retryflow.WithMaxRetries(3),
 		retryflow.WithJitter(50*time.Millisecond),
 		retryflow.WithInitialBackoff(100*time.Millisecond),
@@ -182,11 +187,21 @@ func TestJitter(t *testing.T) {
 	if err == nil {
 		t.Error("expected error, got nil")
 	}
-	duration := time.Since(start)
+	duration := clock.Now().Sub(start)
 	expectedMin := 150 * time.Millisecond
 	if duration < expectedMin {
 		t.Errorf("duration too short: %v (expected > %v)", duration, expectedMin)
 	}
+	backoffs := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}
+	sleeps := clock.Sleeps()
+	if len(sleeps) != len(backoffs) {
+		t.Fatalf("expected %d sleeps, got %v", len(backoffs), sleeps)
+	}
+	for i, d := range sleeps {
+		if d < backoffs[i]-50*time.Millisecond || d > backoffs[i]+50*time.Millisecond {
+			t.Errorf("sleep %d: %v not within 50ms of %v", i+1, d, backoffs[i])
+		}
+	}
 }
 
 func TestMaxElapsedTime(t *testing.T) {
@@ -200,7 +215,9 @@ func TestMaxElapsedTime(t *testing.T) {
 		}).Do(new(int)),
 	)
 
+	// Attempts start at 0, 200, 400 and 600ms; the last one exceeds the budget
 	err := retryflow.Retry(ctx, steps,
+		retryflow.WithClock(retryflowtest.NewClock(time.Now())),
 		retryflow.WithMaxElapsedTime(500*time.Millisecond),
 		retryflow.WithInitialBackoff(200*time.Millisecond),
 		retryflow.WithMaxRetries(-1),
@@ -210,7 +227,7 @@ func TestMaxElapsedTime(t *testing.T) {
 	if err == nil {
 		t.Error("expected error, got nil")
 	}
-	if attempts < 2 || attempts > 4 {
+	if attempts != 4 {
 		t.Errorf("unexpected attempts: %d", attempts)
 	}
 }
@@ -414,3 +431,1726 @@ func TestCheckpointRecovery(t *testing.T) {
 		t.Fatalf("Step3 expected 4 calls, got %d", len(step3Inputs))
 	}
 }
+
+func TestStatsAttemptsToSuccess(t *testing.T) {
+	ctx := context.Background()
+	attempts := 0
+
+	steps := retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (int, error) {
+			attempts++
+			if attempts < 3 {
+				return 0, errors.New("fail")
+			}
+			return 42, nil
+		}).Do(new(int)),
+	)
+
+	var stats retryflow.Stats
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithStats(&stats),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if stats.AttemptsToSuccess != 3 {
+		t.Errorf("expected AttemptsToSuccess 3, got %d", stats.AttemptsToSuccess)
+	}
+
+	err = retryflow.Retry(ctx, retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return errors.New("fail") }),
+	), retryflow.WithStats(&stats), retryflow.WithMaxRetries(1))
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if stats.AttemptsToSuccess != -1 {
+		t.Errorf("expected AttemptsToSuccess -1 on failure, got %d", stats.AttemptsToSuccess)
+	}
+}
+
+func TestJitterClasses(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		jittered bool
+	}{
+		{"RateLimit", errors.New("429"), true},
+		{"Transient", errors.New("connection reset"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := retryflowtest.NewClock(time.Now())
+			err := retryflow.Retry(context.Background(), retryflow.Seq(
+				retryflow.Exec(func(ctx context.Context) error { return tt.err }),
+			),
+				retryflow.WithClock(clock),
+				retryflow.WithMaxRetries(4),
+				retryflow.WithInitialBackoff(20*time.Millisecond),
+				retryflow.WithJitter(10*time.Millisecond),
+				retryflow.WithBackoffStrategy(retryflow.ConstantBackoff),
+				retryflow.WithErrorClassifier(func(err error) retryflow.ErrorClass {
+					if err.Error() == "429" {
+						return retryflow.ClassRateLimit
+					}
+					return retryflow.ClassTransient
+				}),
+				retryflow.WithJitterClasses(retryflow.ClassRateLimit),
+			)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			sleeps := clock.Sleeps()
+			if len(sleeps) != 3 {
+				t.Fatalf("expected 3 backoffs, got %d", len(sleeps))
+			}
+			jittered := false
+			for _, d := range sleeps {
+				if d != 20*time.Millisecond {
+					jittered = true
+				}
+			}
+			if jittered != tt.jittered {
+				t.Errorf("expected jittered=%v, got sleeps %v", tt.jittered, sleeps)
+			}
+		})
+	}
+}
+
+func TestOutputCoercion(t *testing.T) {
+	ctx := context.Background()
+	type userID int64
+	var id userID
+	var total int64
+
+	steps := retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (int, error) {
+			return 42, nil
+		}).Do(&id),
+		retryflow.Chain(func(ctx context.Context, in int64) (int64, error) {
+			return in * 2, nil
+		}).Do(&total),
+	)
+
+	err := retryflow.Retry(ctx, steps, retryflow.WithMaxRetries(1))
+	if err == nil {
+		t.Fatal("expected type mismatch without coercion, got nil")
+	}
+
+	err = retryflow.Retry(ctx, steps, retryflow.WithOutputCoercion(true))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if id != 42 || total != 84 {
+		t.Errorf("expected 42 and 84, got %d and %d", id, total)
+	}
+}
+
+func TestOutputCoercionLossless(t *testing.T) {
+	store := func(out any, dst any) error {
+		return retryflow.Retry(context.Background(), retryflow.Seq(
+			retryflow.Chain(func(ctx context.Context, _ any) (any, error) { return out, nil }).Do(dst),
+		), retryflow.WithOutputCoercion(true), retryflow.WithMaxRetries(1))
+	}
+	var small int8
+	var count int
+	var unsigned uint
+	var ratio float32
+	tests := []struct {
+		name string
+		out  any
+		dst  any
+		ok   bool
+	}{
+		{"IntFits", 100, &small, true},
+		{"IntOverflows", 300, &small, false},
+		{"NegativeToUnsigned", -1, &unsigned, false},
+		{"WholeFloat", 3.0, &count, true},
+		{"FractionalFloat", 3.7, &count, false},
+		{"FloatOverflows", 1e40, &ratio, false},
+		{"FloatFits", 0.5, &ratio, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := store(tt.out, tt.dst); (err == nil) != tt.ok {
+				t.Errorf("expected ok=%v storing %v, got %v", tt.ok, tt.out, err)
+			}
+		})
+	}
+	if small != 100 || count != 3 || ratio != 0.5 {
+		t.Errorf("expected 100, 3 and 0.5, got %d, %d and %v", small, count, ratio)
+	}
+}
+
+func TestWakeupChannel(t *testing.T) {
+	ctx := context.Background()
+	wakeup := make(chan struct{})
+	attempts := 0
+
+	steps := retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			attempts++
+			if attempts == 1 {
+				return errors.New("fail")
+			}
+			return nil
+		}),
+	)
+
+	time.AfterFunc(50*time.Millisecond, func() { wakeup <- struct{}{} })
+
+	start := time.Now()
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithInitialBackoff(5*time.Second),
+		retryflow.WithBackoffStrategy(retryflow.ConstantBackoff),
+		retryflow.WithJitter(0),
+		retryflow.WithWakeupChannel(wakeup),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("retry was not woken up early: %v", elapsed)
+	}
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+}
+
+func TestStatsLastErrorByClass(t *testing.T) {
+	ctx := context.Background()
+	failures := []error{
+		errors.New("timeout 1"),
+		errors.New("ratelimit 1"),
+		errors.New("timeout 2"),
+		errors.New("ratelimit 2"),
+		errors.New("auth 1"),
+	}
+	attempts := 0
+
+	steps := retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			attempts++
+			if attempts <= len(failures) {
+				return failures[attempts-1]
+			}
+			return nil
+		}),
+	)
+
+	var stats retryflow.Stats
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithStats(&stats),
+		retryflow.WithMaxRetries(10),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithErrorClassifier(func(err error) retryflow.ErrorClass {
+			msg := err.Error()
+			return retryflow.ErrorClass(msg[:strings.Index(msg, " ")])
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	want := map[retryflow.ErrorClass]error{
+		"timeout":   failures[2],
+		"ratelimit": failures[3],
+		"auth":      failures[4],
+	}
+	if len(stats.LastErrorByClass) != len(want) {
+		t.Fatalf("expected %d classes, got %v", len(want), stats.LastErrorByClass)
+	}
+	for class, wantErr := range want {
+		if got := stats.LastErrorByClass[class]; !errors.Is(got, wantErr) {
+			t.Errorf("class %s: expected %v, got %v", class, wantErr, got)
+		}
+	}
+}
+
+func TestSeqCopiesSteps(t *testing.T) {
+	ctx := context.Background()
+	var ran []string
+
+	list := []*retryflow.Step{
+		retryflow.Exec(func(ctx context.Context) error { ran = append(ran, "a"); return nil }),
+		retryflow.Exec(func(ctx context.Context) error { ran = append(ran, "b"); return nil }),
+	}
+	steps := retryflow.Seq(list...)
+
+	list[1] = retryflow.Exec(func(ctx context.Context) error { ran = append(ran, "mutated"); return nil })
+
+	if err := retryflow.Retry(ctx, steps); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if strings.Join(ran, ",") != "a,b" {
+		t.Errorf("expected steps a,b to run, got %v", ran)
+	}
+}
+
+func TestRemainingRetriesFallback(t *testing.T) {
+	ctx := context.Background()
+	var remaining []int
+	var result string
+
+	steps := retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (string, error) {
+			n, ok := retryflow.RemainingRetries(ctx)
+			if !ok {
+				return "", errors.New("remaining retries not reported")
+			}
+			remaining = append(remaining, n)
+			if n == 0 {
+				return "cached", nil
+			}
+			return "", errors.New("fail")
+		}).Do(&result),
+	)
+
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithMaxRetries(3),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if err != nil {
+		t.Fatalf("expected fallback success, got %v", err)
+	}
+	if result != "cached" {
+		t.Errorf("expected cached fallback, got %q", result)
+	}
+	if fmt.Sprint(remaining) != "[2 1 0]" {
+		t.Errorf("expected remaining retries [2 1 0], got %v", remaining)
+	}
+}
+
+func TestRemainingBudget(t *testing.T) {
+	var budget time.Duration
+	var ok bool
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			budget, ok = retryflow.RemainingBudget(ctx)
+			return nil
+		}),
+	), retryflow.WithMaxElapsedTime(time.Minute))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !ok || budget <= 50*time.Second || budget > time.Minute {
+		t.Errorf("expected close to a minute of budget, got %v (ok=%v)", budget, ok)
+	}
+	if _, ok := retryflow.RemainingBudget(context.Background()); ok {
+		t.Error("expected no budget outside a flow")
+	}
+}
+
+func TestAutoCheckpointEvery(t *testing.T) {
+	ctx := context.Background()
+	runs := make([]int, 6)
+	failOnce := map[int]bool{2: true, 4: true}
+
+	var steps []*retryflow.Step
+	for i := range runs {
+		steps = append(steps, retryflow.Chain(func(ctx context.Context, in int) (int, error) {
+			runs[i]++
+			if in != i {
+				return 0, fmt.Errorf("step %d resumed with input %d", i+1, in)
+			}
+			if failOnce[i] {
+				failOnce[i] = false
+				return 0, errors.New("fail")
+			}
+			return i + 1, nil
+		}))
+	}
+
+	opts := []retryflow.Option{
+		retryflow.WithAutoCheckpointEvery(2),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	}
+	err := retryflow.Retry(ctx, retryflow.Seq(steps...), opts...)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// Failures at steps 3 and 5 resume from the checkpoints at steps 2 and 4.
+	if fmt.Sprint(runs) != "[1 1 2 1 2 1]" {
+		t.Errorf("unexpected step runs: %v", runs)
+	}
+
+	c, err := retryflow.RetryUntilCheckpoint(ctx, retryflow.Seq(steps...), opts...)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if c.Checkpoint() != 2 {
+		t.Errorf("expected first checkpoint at step 2, got %d", c.Checkpoint())
+	}
+}
+
+func TestCaptureSteps(t *testing.T) {
+	ctx := context.Background()
+	steps := retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (int, error) { return 1, nil }),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) { return in + 1, nil }),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) { return in + 1, nil }),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) { return in + 1, nil }),
+	)
+
+	var stats retryflow.Stats
+	if err := retryflow.Retry(ctx, steps, retryflow.WithStats(&stats)); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(stats.Outputs) != 4 {
+		t.Errorf("expected all 4 outputs by default, got %v", stats.Outputs)
+	}
+
+	err := retryflow.Retry(ctx, steps, retryflow.WithStats(&stats), retryflow.WithCaptureSteps(2, 4))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want := map[int]any{2: 2, 4: 4}
+	if fmt.Sprint(stats.Outputs) != fmt.Sprint(want) {
+		t.Errorf("expected outputs %v, got %v", want, stats.Outputs)
+	}
+}
+
+func TestStaleCheckpointRestartsFlow(t *testing.T) {
+	ctx := context.Background()
+	tokens := 0
+	var result string
+
+	steps := retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (string, error) {
+			tokens++
+			return fmt.Sprintf("token-%d", tokens), nil
+		}).Checkpoint(),
+		retryflow.Chain(func(ctx context.Context, token string) (string, error) {
+			return token, nil
+		}).Checkpoint(),
+		retryflow.Chain(func(ctx context.Context, token string) (string, error) {
+			if token == "token-1" {
+				return "", fmt.Errorf("token expired: %w", retryflow.ErrStaleCheckpoint)
+			}
+			return "used " + token, nil
+		}).Do(&result),
+	)
+
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithRetryable(func(err error) bool { return false }),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if tokens != 2 {
+		t.Errorf("expected step 1 to re-run once, got %d runs", tokens)
+	}
+	if result != "used token-2" {
+		t.Errorf("expected fresh token to be used, got %q", result)
+	}
+}
+
+func TestStepStore(t *testing.T) {
+	ctx := context.Background()
+	collected := map[string]any{}
+	outputs := make(chan any, 2)
+
+	steps := retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (string, error) {
+			return "token", nil
+		}).Store(func(output any) { collected["token"] = output }),
+		retryflow.Chain(func(ctx context.Context, token string) (int, error) {
+			return len(token), nil
+		}).Store(func(output any) { outputs <- output }),
+	)
+
+	if err := retryflow.Retry(ctx, steps); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if collected["token"] != "token" {
+		t.Errorf("expected token in map, got %v", collected)
+	}
+	if got := <-outputs; got != 5 {
+		t.Errorf("expected 5 on channel, got %v", got)
+	}
+}
+
+func TestFlowKeySharesExecution(t *testing.T) {
+	ctx := context.Background()
+	var executions atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	newSteps := func() retryflow.Steps {
+		return retryflow.Seq(
+			retryflow.Exec(func(ctx context.Context) error {
+				if executions.Add(1) == 1 {
+					close(started)
+				}
+				<-release
+				return nil
+			}),
+		)
+	}
+
+	errs := make(chan error, 2)
+	go func() { errs <- retryflow.Retry(ctx, newSteps(), retryflow.WithFlowKey("order-42")) }()
+	<-started
+	go func() { errs <- retryflow.Retry(ctx, newSteps(), retryflow.WithFlowKey("order-42")) }()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	}
+	if executions.Load() != 1 {
+		t.Errorf("expected steps to execute once, got %d", executions.Load())
+	}
+}
+
+func TestFlowKeyCallerContext(t *testing.T) {
+	var executions atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	steps := retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			executions.Add(1)
+			close(started)
+			select {
+			case <-release:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}),
+	)
+	opt := retryflow.WithFlowKey("caller-context")
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() { first <- retryflow.Retry(firstCtx, steps, opt) }()
+	<-started
+	second := make(chan error, 1)
+	go func() { second <- retryflow.Retry(context.Background(), steps, opt) }()
+
+	// A joining caller whose ctx expires stops waiting
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	begin := time.Now()
+	if err := retryflow.Retry(ctx, steps, opt); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(begin); elapsed > 500*time.Millisecond {
+		t.Errorf("expected the caller to return on its deadline, took %v", elapsed)
+	}
+
+	// Cancelling the caller that started the flow leaves it running for the others
+	cancelFirst()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	close(release)
+	if err := <-second; err != nil {
+		t.Errorf("expected the shared flow to succeed, got %v", err)
+	}
+	if executions.Load() != 1 {
+		t.Errorf("expected steps to execute once, got %d", executions.Load())
+	}
+}
+
+func TestSimpleExponential(t *testing.T) {
+	clock := retryflowtest.NewClock(time.Now())
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return errors.New("fail") }),
+	),
+		retryflow.WithClock(clock),
+		retryflow.WithSimpleExponential(20*time.Millisecond, 100*time.Millisecond, 0.25),
+		retryflow.WithMaxRetries(6),
+	)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	sleeps := clock.Sleeps()
+
+	want := []time.Duration{20, 40, 80, 100, 100}
+	if len(sleeps) != len(want) {
+		t.Fatalf("expected %d sleeps, got %v", len(want), sleeps)
+	}
+	for i, w := range want {
+		w *= time.Millisecond
+		lo, hi := w-w/4, w+w/4
+		if sleeps[i] < lo || sleeps[i] > hi {
+			t.Errorf("sleep %d: %v outside [%v, %v]", i+1, sleeps[i], lo, hi)
+		}
+	}
+}
+
+func TestOnStepSkip(t *testing.T) {
+	ctx := context.Background()
+	type skip struct {
+		step   int
+		reason retryflow.SkipReason
+	}
+	var skips []skip
+	var result int
+	ranSkipped := false
+
+	steps := retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (int, error) {
+			return 1, nil
+		}),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) {
+			ranSkipped = true
+			return in * 100, nil
+		}).When(func(input any) bool { return input.(int) > 1 }),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) {
+			return 0, errors.New("optional enrichment failed")
+		}).Optional(),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) {
+			ranSkipped = true
+			return 0, nil
+		}).MinBudget(time.Hour),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) {
+			return in + 1, nil
+		}).Do(&result),
+	)
+
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithMaxElapsedTime(time.Minute),
+		retryflow.WithOnStepSkip(func(step int, reason retryflow.SkipReason) {
+			skips = append(skips, skip{step, reason})
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want := []skip{
+		{2, retryflow.ReasonPredicate},
+		{3, retryflow.ReasonOptionalFailure},
+		{4, retryflow.ReasonBudget},
+	}
+	if fmt.Sprint(skips) != fmt.Sprint(want) {
+		t.Errorf("expected skips %v, got %v", want, skips)
+	}
+	if ranSkipped {
+		t.Error("a skipped step was executed")
+	}
+	if result != 2 {
+		t.Errorf("expected step 1 output to pass through skipped steps, got %d", result)
+	}
+}
+
+func TestWhenToggledAcrossAttempts(t *testing.T) {
+	attempts := 0
+	step1Runs := 0
+	skipped := 0
+	gated := -1
+	var gatedDuringRetries []int
+	var step3Inputs []int
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (int, error) {
+			attempts++
+			step1Runs++
+			return attempts, nil
+		}),
+		// The predicate only holds on even attempts
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) {
+			return in * 10, nil
+		}).When(func(input any) bool { return input.(int)%2 == 0 }).Do(&gated).Checkpoint(),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) {
+			step3Inputs = append(step3Inputs, in)
+			if len(step3Inputs) < 3 {
+				return 0, errors.New("not yet")
+			}
+			return in + 1, nil
+		}),
+	),
+		retryflow.WithOnStepSkip(func(step int, reason retryflow.SkipReason) { skipped++ }),
+		retryflow.WithOnRetry(func(attempt int, err error) {
+			gatedDuringRetries = append(gatedDuringRetries, gated)
+		}),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// Attempt 1 skips the checkpoint, so attempt 2 starts over from step 1;
+	// attempt 2 commits it, so attempt 3 resumes at step 3
+	if step1Runs != 2 {
+		t.Errorf("expected step 1 to run twice, got %d", step1Runs)
+	}
+	if skipped != 1 {
+		t.Errorf("expected one skip, got %d", skipped)
+	}
+	if want := []int{1, 20, 20}; !slices.Equal(step3Inputs, want) {
+		t.Errorf("expected step 3 inputs %v, got %v", want, step3Inputs)
+	}
+	if want := []int{-1, 20}; !slices.Equal(gatedDuringRetries, want) {
+		t.Errorf("expected the skipped step's output to stay untouched, got %v", gatedDuringRetries)
+	}
+}
+
+func TestRetryFromLabel(t *testing.T) {
+	ctx := context.Background()
+	runs := make([]int, 5)
+	var prepareInputs []int
+	failed := false
+
+	steps := retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (int, error) { runs[0]++; return 1, nil }),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) { runs[1]++; return in + 1, nil }),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) {
+			runs[2]++
+			prepareInputs = append(prepareInputs, in)
+			return in + 1, nil
+		}).Label("prepare"),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) { runs[3]++; return in + 1, nil }),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) {
+			runs[4]++
+			if !failed {
+				failed = true
+				return 0, errors.New("prepared state rejected")
+			}
+			return in + 1, nil
+		}).RetryFrom("prepare"),
+	)
+
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if fmt.Sprint(runs) != "[1 1 2 2 2]" {
+		t.Errorf("expected resume from step 3, got runs %v", runs)
+	}
+	if fmt.Sprint(prepareInputs) != "[2 2]" {
+		t.Errorf("expected step 3 to receive step 2's output each time, got %v", prepareInputs)
+	}
+}
+
+func TestRetryFromUnknownLabel(t *testing.T) {
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return nil }).RetryFrom("missing"),
+	))
+	if err == nil || !strings.Contains(err.Error(), `"missing"`) {
+		t.Errorf("expected unknown label error, got %v", err)
+	}
+}
+
+func TestRetryFromInvalidLabels(t *testing.T) {
+	noop := func(ctx context.Context) error { return nil }
+	tests := []struct {
+		name  string
+		steps retryflow.Steps
+		want  string
+	}{
+		{"Duplicate", retryflow.Seq(
+			retryflow.Exec(noop).Label("a"),
+			retryflow.Exec(noop).Label("a"),
+		), `step 2: duplicate label "a", already on step 1`},
+		{"LaterStep", retryflow.Seq(
+			retryflow.Exec(noop),
+			retryflow.Exec(func(ctx context.Context) error { return errors.New("fail") }).RetryFrom("c"),
+			retryflow.Exec(noop).Label("c"),
+		), `step 2: RetryFrom label "c" of the later step 3`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := retryflow.Retry(context.Background(), tt.steps)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestRetryFromBeforeLoadedCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	store := &retryflow.MemoryCheckpointStore{}
+	if err := store.Save(ctx, "k", 2, 20); err != nil {
+		t.Fatal(err)
+	}
+	runs := make([]int, 3)
+	var inputs []int
+	failed := false
+
+	err := retryflow.Retry(ctx, retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (int, error) { runs[0]++; return 10, nil }).Label("start"),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) { runs[1]++; return 20, nil }).Checkpoint(),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) {
+			runs[2]++
+			inputs = append(inputs, in)
+			if !failed {
+				failed = true
+				return 0, errors.New("fail")
+			}
+			return in + 1, nil
+		}).RetryFrom("start"),
+	),
+		retryflow.WithCheckpointStore(store, "k"),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// The committed steps stay committed and step 3 gets the checkpoint output
+	if fmt.Sprint(runs) != "[0 0 2]" || fmt.Sprint(inputs) != "[20 20]" {
+		t.Errorf("expected step 3 to resume from the checkpoint, got runs %v and inputs %v", runs, inputs)
+	}
+}
+
+func TestMaxIterationsDetectsCheckpointLoop(t *testing.T) {
+	ctx := context.Background()
+	attempts := 0
+
+	steps := retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			attempts++
+			return nil
+		}).Checkpoint(),
+		retryflow.Exec(func(ctx context.Context) error {
+			return retryflow.ErrStaleCheckpoint
+		}),
+	)
+
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithMaxRetries(3),
+		retryflow.WithMaxIterations(20),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	var loopErr *retryflow.LoopDetectedError
+	if !errors.As(err, &loopErr) {
+		t.Fatalf("expected LoopDetectedError, got %v", err)
+	}
+	if loopErr.Iterations != 20 || attempts != 20 {
+		t.Errorf("expected 20 iterations, got %d (%d attempts)", loopErr.Iterations, attempts)
+	}
+	if !errors.Is(err, retryflow.ErrStaleCheckpoint) {
+		t.Errorf("expected last error to be wrapped, got %v", err)
+	}
+}
+
+func TestAttemptAndStepFromContext(t *testing.T) {
+	var seen []string
+	record := func(ctx context.Context) {
+		attempt, okAttempt := retryflow.AttemptFromContext(ctx)
+		step, okStep := retryflow.StepFromContext(ctx)
+		if !okAttempt || !okStep {
+			t.Errorf("expected attempt and step in the step context")
+		}
+		seen = append(seen, fmt.Sprintf("%d/%d", attempt, step))
+	}
+	calls := 0
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			record(ctx)
+			return nil
+		}),
+		retryflow.Exec(func(ctx context.Context) error {
+			record(ctx)
+			calls++
+			if calls < 3 {
+				return errors.New("fail")
+			}
+			return nil
+		}).Timeout(time.Second),
+	),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want := []string{"1/1", "1/2", "2/1", "2/2", "3/1", "3/2"}
+	if !slices.Equal(seen, want) {
+		t.Errorf("expected attempt/step %v, got %v", want, seen)
+	}
+
+	if _, ok := retryflow.AttemptFromContext(context.Background()); ok {
+		t.Error("expected no attempt outside a flow")
+	}
+	if _, ok := retryflow.StepFromContext(context.Background()); ok {
+		t.Error("expected no step outside a flow")
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	run := func(opts ...retryflow.Option) []string {
+		var keys []string
+		calls := 0
+		steps := retryflow.Seq(
+			retryflow.Exec(func(ctx context.Context) error {
+				keys = append(keys, retryflow.IdempotencyKeyFromContext(ctx))
+				return nil
+			}).Checkpoint(),
+			retryflow.Exec(func(ctx context.Context) error {
+				keys = append(keys, retryflow.IdempotencyKeyFromContext(ctx))
+				calls++
+				if calls < 3 {
+					return errors.New("fail")
+				}
+				return nil
+			}),
+		)
+		opts = append(opts, retryflow.WithInitialBackoff(time.Millisecond), retryflow.WithJitter(0))
+		if err := retryflow.Retry(context.Background(), steps, opts...); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		return keys
+	}
+
+	first := run()
+	if len(first) != 4 {
+		t.Fatalf("expected 4 step runs, got %d", len(first))
+	}
+	for _, k := range first {
+		if k != first[0] {
+			t.Fatalf("expected the key to stay the same across attempts and checkpoints, got %v", first)
+		}
+	}
+	if len(first[0]) != 36 || first[0][14] != '4' {
+		t.Errorf("expected a version 4 UUID, got %q", first[0])
+	}
+	if second := run(); second[0] == first[0] {
+		t.Errorf("expected each Retry call to get its own key, got %q twice", first[0])
+	}
+	if keys := run(retryflow.WithIdempotencyKey("order-42")); keys[0] != "order-42" || keys[3] != "order-42" {
+		t.Errorf("expected the configured key, got %v", keys)
+	}
+	if key := retryflow.IdempotencyKeyFromContext(context.Background()); key != "" {
+		t.Errorf("expected no key outside a flow, got %q", key)
+	}
+}
+
+func TestFlowStore(t *testing.T) {
+	ctx := context.Background()
+	attempts := 0
+	var report string
+
+	addWarning := func(ctx context.Context, w string) {
+		store := retryflow.FlowStore(ctx)
+		v, _ := store.LoadOrStore("warnings", &[]string{})
+		warnings := v.(*[]string)
+		*warnings = append(*warnings, w)
+	}
+
+	steps := retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			addWarning(ctx, "cache cold")
+			return nil
+		}).Checkpoint(),
+		retryflow.Exec(func(ctx context.Context) error {
+			attempts++
+			if attempts == 1 {
+				addWarning(ctx, "slow replica")
+				return errors.New("fail")
+			}
+			return nil
+		}),
+		retryflow.Chain(func(ctx context.Context, _ any) (string, error) {
+			v, ok := retryflow.FlowStore(ctx).Load("warnings")
+			if !ok {
+				return "", errors.New("no warnings stored")
+			}
+			return strings.Join(*v.(*[]string), ";"), nil
+		}).Do(&report),
+	)
+
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if report != "cache cold;slow replica" {
+		t.Errorf("expected warnings from all attempts, got %q", report)
+	}
+	if retryflow.FlowStore(ctx) != nil {
+		t.Error("expected no flow store outside a flow")
+	}
+}
+
+func TestReadinessProbe(t *testing.T) {
+	ctx := context.Background()
+	attempts := 0
+	var ready atomic.Bool
+	time.AfterFunc(50*time.Millisecond, func() { ready.Store(true) })
+
+	steps := retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			attempts++
+			if attempts == 1 {
+				return errors.New("dependency down")
+			}
+			return nil
+		}),
+	)
+
+	start := time.Now()
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithInitialBackoff(5*time.Second),
+		retryflow.WithBackoffStrategy(retryflow.ConstantBackoff),
+		retryflow.WithJitter(0),
+		retryflow.WithReadinessProbe(func(ctx context.Context) bool { return ready.Load() }),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("retry did not start early once ready: %v", elapsed)
+	}
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+}
+
+func TestBudgetFraction(t *testing.T) {
+	var timeout time.Duration
+	var hasDeadline bool
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			var deadline time.Time
+			deadline, hasDeadline = ctx.Deadline()
+			timeout = time.Until(deadline)
+			return nil
+		}).BudgetFraction(0.5),
+	), retryflow.WithMaxElapsedTime(10*time.Second))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !hasDeadline || timeout < 4*time.Second || timeout > 5*time.Second {
+		t.Errorf("expected a deadline of about half the 10s budget, got %v (deadline=%v)", timeout, hasDeadline)
+	}
+}
+
+func TestBudgetFractionWithClock(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctxLeft  time.Duration
+		elapsed  time.Duration
+		min, max time.Duration
+	}{
+		// The context deadline is real time, whatever the flow's clock says
+		{"ContextDeadline", 10 * time.Second, 5 * time.Minute, 4 * time.Second, 5 * time.Second},
+		// maxElapsedTime is measured on the flow's clock
+		{"MaxElapsedTime", time.Hour, 4 * time.Second, time.Second, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), tt.ctxLeft)
+			defer cancel()
+			clock := retryflowtest.NewClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+			var timeout time.Duration
+
+			err := retryflow.Retry(ctx, retryflow.Seq(
+				retryflow.Exec(func(ctx context.Context) error {
+					deadline, _ := ctx.Deadline()
+					timeout = time.Until(deadline)
+					return nil
+				}).BudgetFraction(0.5),
+			), retryflow.WithClock(clock), retryflow.WithMaxElapsedTime(tt.elapsed))
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if timeout < tt.min || timeout > tt.max {
+				t.Errorf("expected a step deadline in [%v, %v], got %v", tt.min, tt.max, timeout)
+			}
+		})
+	}
+}
+
+func TestWholeFlowRetryIgnoresCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	firstRuns := 0
+	secondRuns := 0
+
+	steps := retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			firstRuns++
+			return nil
+		}).Checkpoint(),
+		retryflow.Exec(func(ctx context.Context) error {
+			secondRuns++
+			if secondRuns < 3 {
+				return errors.New("fail")
+			}
+			return nil
+		}),
+	)
+
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithWholeFlowRetry(true),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if firstRuns != 3 || secondRuns != 3 {
+		t.Errorf("expected both steps to run 3 times, got %d and %d", firstRuns, secondRuns)
+	}
+}
+
+func TestAdjacentCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	runs := make([]int, 3)
+	var result string
+
+	steps := retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (string, error) {
+			runs[0]++
+			return "a", nil
+		}).Checkpoint(),
+		retryflow.Chain(func(ctx context.Context, in string) (string, error) {
+			runs[1]++
+			return in + "b", nil
+		}).Checkpoint(),
+		retryflow.Chain(func(ctx context.Context, in string) (string, error) {
+			runs[2]++
+			if runs[2] == 1 {
+				return "", errors.New("fail")
+			}
+			return in + "c", nil
+		}).Do(&result),
+	)
+
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if fmt.Sprint(runs) != "[1 1 2]" {
+		t.Errorf("expected resume after the second checkpoint, got runs %v", runs)
+	}
+	if result != "abc" {
+		t.Errorf("expected the last checkpoint output to be resumed, got %q", result)
+	}
+}
+
+func TestInterfaceOutputPtr(t *testing.T) {
+	ctx := context.Background()
+	errOut := errors.New("sentinel output")
+	var storedErr error
+	reader := io.Reader(strings.NewReader("stale"))
+	steps := retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (error, error) {
+			return fmt.Errorf("wrapped: %w", errOut), nil
+		}).Do(&storedErr),
+		retryflow.Chain(func(ctx context.Context, _ error) (io.Reader, error) {
+			return nil, nil
+		}).Do(&reader),
+	)
+
+	if err := retryflow.Retry(ctx, steps, retryflow.WithJitter(0)); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !errors.Is(storedErr, errOut) {
+		t.Errorf("expected stored error wrapping %v, got %v", errOut, storedErr)
+	}
+	if reader != nil {
+		t.Errorf("expected nil reader, got %v", reader)
+	}
+}
+
+func TestStepTimeout(t *testing.T) {
+	ctx := context.Background()
+	var calls, failures int
+	var retryErr error
+	steps := retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			calls++
+			if calls == 1 {
+				<-ctx.Done() // hang until the step deadline
+				return ctx.Err()
+			}
+			return nil
+		}).Timeout(10 * time.Millisecond).OnFail(func() { failures++ }),
+	)
+
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithOnRetry(func(attempt int, err error) { retryErr = err }),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if calls != 2 || failures != 1 {
+		t.Errorf("expected 2 calls and 1 failure, got %d calls and %d failures", calls, failures)
+	}
+	if !errors.Is(retryErr, context.DeadlineExceeded) {
+		t.Errorf("expected retry error wrapping context.DeadlineExceeded, got %v", retryErr)
+	}
+}
+
+func TestPreflightCheck(t *testing.T) {
+	ctx := context.Background()
+	var events []string
+	checks := 0
+	steps := retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			events = append(events, "step")
+			return nil
+		}),
+	)
+
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithPreflightCheck(func(ctx context.Context) error {
+			checks++
+			events = append(events, "preflight")
+			if checks <= 2 {
+				return errors.New("dependency down")
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want := "preflight,preflight,preflight,step"
+	if got := strings.Join(events, ","); got != want {
+		t.Errorf("expected events %s, got %s", want, got)
+	}
+}
+
+func TestOnStepOutputDiff(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	type diff struct {
+		step       int
+		prev, curr any
+	}
+	var diffs []diff
+	steps := retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (int, error) {
+			calls++
+			return min(calls, 2), nil
+		}),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) {
+			if calls < 3 {
+				return 0, errors.New("fail")
+			}
+			return in, nil
+		}),
+	)
+
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithOnStepOutputDiff(func(step int, prev, curr any) {
+			diffs = append(diffs, diff{step, prev, curr})
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(diffs) != 1 || diffs[0] != (diff{1, 1, 2}) {
+		t.Errorf("expected a single diff of step 1 from 1 to 2, got %+v", diffs)
+	}
+}
+
+func TestOnCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	var committed []int
+	var outputs []any
+	steps := retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (int, error) { return 1, nil }),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) { return in + 1, nil }).Checkpoint(),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) { return in + 1, nil }),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) { return in + 1, nil }).Checkpoint(),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) { return in + 1, nil }),
+	)
+
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithOnCheckpoint(func(step int, output any) {
+			committed = append(committed, step)
+			outputs = append(outputs, output)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if fmt.Sprint(committed) != "[2 4]" || fmt.Sprint(outputs) != "[2 4]" {
+		t.Errorf("expected checkpoints [2 4] with outputs [2 4], got %v with %v", committed, outputs)
+	}
+}
+
+func TestStatsExecutedSteps(t *testing.T) {
+	ctx := context.Background()
+	attempts := 0
+	is := func(branch string) func(any) bool {
+		return func(in any) bool { return in == branch }
+	}
+	steps := retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (string, error) {
+			attempts++
+			if attempts == 1 {
+				return "primary", nil
+			}
+			return "fallback", nil
+		}),
+		retryflow.Exec(func(ctx context.Context) error { return errors.New("primary down") }).When(is("primary")),
+		retryflow.Exec(func(ctx context.Context) error { return nil }).When(is("fallback")),
+	)
+
+	var stats retryflow.Stats
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithStats(&stats),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := fmt.Sprint(stats.ExecutedSteps); got != "[[1 2] [1 3]]" {
+		t.Errorf("expected executed steps [[1 2] [1 3]], got %s", got)
+	}
+}
+
+func TestMaxEventHistory(t *testing.T) {
+	attempts := 0
+	var stats retryflow.Stats
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return nil }),
+		retryflow.Exec(func(ctx context.Context) error {
+			attempts++
+			if attempts < 100 {
+				return errors.New("fail")
+			}
+			return nil
+		}),
+		retryflow.Exec(func(ctx context.Context) error { return nil }),
+	),
+		retryflow.WithStats(&stats),
+		retryflow.WithMaxEventHistory(5),
+		retryflow.WithMaxRetries(100),
+		retryflow.WithMaxElapsedTime(0),
+		retryflow.WithClock(retryflowtest.NewClock(time.Now())),
+		retryflow.WithJitter(0),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// The latest attempts are kept, the last one being the success
+	if got := fmt.Sprint(stats.ExecutedSteps); got != "[[1 2] [1 2] [1 2] [1 2] [1 2 3]]" {
+		t.Errorf("expected the last 5 attempts, got %s", got)
+	}
+}
+
+func TestContextDeadlineSkipsFinalBackoff(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	errFail := errors.New("fail")
+
+	start := time.Now()
+	err := retryflow.Retry(ctx, retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return errFail }),
+	),
+		retryflow.WithInitialBackoff(time.Second),
+		retryflow.WithJitter(0),
+	)
+	if !errors.Is(err, errFail) {
+		t.Errorf("expected the step error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("expected Retry to return promptly, took %v", elapsed)
+	}
+}
+
+func TestStopRetrying(t *testing.T) {
+	ctx := context.Background()
+	errShutdown := errors.New("shutting down")
+	attempts := 0
+	var reason retryflow.GiveUpReason
+	steps := retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			attempts++
+			if attempts == 2 {
+				retryflow.StopRetrying(ctx)
+				return errShutdown
+			}
+			return errors.New("fail")
+		}),
+	)
+
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithOnGiveUp(func(attempt int, err error, r retryflow.GiveUpReason) { reason = r }),
+	)
+	if !errors.Is(err, errShutdown) {
+		t.Fatalf("expected errShutdown, got %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("expected no attempt after StopRetrying, got %d attempts", attempts)
+	}
+	if reason != retryflow.GiveUpStopped {
+		t.Errorf("expected GiveUpStopped, got %v", reason)
+	}
+}
+
+func TestOnStepStart(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	starts := make(map[int]int)
+	successes := make(map[int]int)
+	var inputs []any
+	steps := retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (int, error) { return 1, nil }).Checkpoint(),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) {
+			calls++
+			if calls < 3 {
+				return 0, errors.New("fail")
+			}
+			return in + 1, nil
+		}),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) { return in + 1, nil }),
+	)
+
+	err := retryflow.Retry(ctx, steps,
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithOnStepStart(func(step int, input any) {
+			starts[step]++
+			if step == 2 {
+				inputs = append(inputs, input)
+			}
+		}),
+		retryflow.WithOnStepSuccess(func(step int, output any) { successes[step]++ }),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if starts[1] != 1 || starts[2] != 3 || starts[3] != 1 {
+		t.Errorf("expected starts 1, 3, 1, got %v", starts)
+	}
+	if starts[2]-successes[2] != 2 || successes[1] != starts[1] || successes[3] != starts[3] {
+		t.Errorf("start and success counts differ by more than the 2 failures: %v vs %v", starts, successes)
+	}
+	if fmt.Sprint(inputs) != "[1 1 1]" {
+		t.Errorf("expected the checkpoint output as input on every attempt, got %v", inputs)
+	}
+}
+
+func TestCanceler(t *testing.T) {
+	var canceler retryflow.Canceler
+	attempts := 0
+
+	start := time.Now()
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			attempts++
+			return errors.New("fail")
+		}),
+	),
+		retryflow.WithCanceler(&canceler),
+		retryflow.WithInitialBackoff(5*time.Second),
+		retryflow.WithJitter(0),
+		retryflow.WithOnRetry(func(attempt int, err error) {
+			go func() {
+				time.Sleep(10 * time.Millisecond) // cancel mid-backoff
+				canceler.Cancel()
+			}()
+		}),
+	)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", attempts)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the backoff to be interrupted, took %v", elapsed)
+	}
+
+	// A cancelled Canceler stops later flows immediately
+	err = retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return nil }),
+	), retryflow.WithCanceler(&canceler))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestCancelerSharedByFlows(t *testing.T) {
+	var canceler retryflow.Canceler
+	blocking := func(started chan<- struct{}) retryflow.Steps {
+		return retryflow.Seq(retryflow.Exec(func(ctx context.Context) error {
+			started <- struct{}{}
+			<-ctx.Done()
+			return ctx.Err()
+		}))
+	}
+	started := make(chan struct{}, 2)
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			errs <- retryflow.Retry(context.Background(), blocking(started), retryflow.WithCanceler(&canceler))
+		}()
+	}
+	<-started
+	<-started
+
+	// A flow finishing does not unregister the others
+	if err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return nil }),
+	), retryflow.WithCanceler(&canceler)); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	canceler.Cancel()
+	for range 2 {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("expected context.Canceled, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected Cancel to stop every registered flow")
+		}
+	}
+}
+func TestStepMaxAttempts(t *testing.T) {
+	errFlaky := errors.New("flaky")
+	runs := 0
+	var reason retryflow.GiveUpReason
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return nil }).Label("start"),
+		retryflow.Exec(func(ctx context.Context) error {
+			runs++
+			return errFlaky
+		}).RetryFrom("start").MaxAttempts(5),
+	),
+		retryflow.WithMaxRetries(10),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithOnGiveUp(func(attempt int, err error, r retryflow.GiveUpReason) { reason = r }),
+	)
+	var exhausted *retryflow.StepExhaustedError
+	if !errors.As(err, &exhausted) {
+		t.Fatalf("expected *StepExhaustedError, got %v", err)
+	}
+	if exhausted.Step != 2 || exhausted.Attempts != 5 {
+		t.Errorf("expected step 2 exhausted after 5 attempts, got step %d after %d", exhausted.Step, exhausted.Attempts)
+	}
+	if !errors.Is(err, errFlaky) {
+		t.Errorf("expected the last error to be wrapped, got %v", err)
+	}
+	if runs != 5 {
+		t.Errorf("expected exactly 5 runs, got %d", runs)
+	}
+	if reason != retryflow.GiveUpStepExhausted {
+		t.Errorf("expected GiveUpStepExhausted, got %v", reason)
+	}
+}
+
+func TestCollapseRepeatedRetries(t *testing.T) {
+	errRateLimit := errors.New("429 too many requests")
+	errUnavailable := errors.New("503 unavailable")
+
+	tests := []struct {
+		name   string
+		errs   []error
+		counts []int
+	}{
+		{"Identical", []error{errRateLimit, errRateLimit, errRateLimit, errRateLimit, errRateLimit}, []int{5}},
+		{"Changing", []error{errRateLimit, errRateLimit, errUnavailable, errRateLimit}, []int{2, 1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			var counts []int
+			err := retryflow.Retry(context.Background(), retryflow.Seq(
+				retryflow.Exec(func(ctx context.Context) error {
+					attempts++
+					if attempts <= len(tt.errs) {
+						return tt.errs[attempts-1]
+					}
+					return nil
+				}),
+			),
+				retryflow.WithCollapseRepeatedRetries(true),
+				retryflow.WithErrorClassifier(func(err error) retryflow.ErrorClass { return retryflow.ClassRateLimit }),
+				retryflow.WithOnRetry(func(attempt int, err error) {
+					var repeated *retryflow.RepeatedError
+					if !errors.As(err, &repeated) {
+						t.Fatalf("expected *RepeatedError, got %v", err)
+					}
+					counts = append(counts, repeated.Count)
+				}),
+				retryflow.WithMaxRetries(10),
+				retryflow.WithInitialBackoff(time.Millisecond),
+				retryflow.WithJitter(0),
+			)
+			if err != nil {
+				t.Fatalf("expected success, got %v", err)
+			}
+			if !slices.Equal(counts, tt.counts) {
+				t.Errorf("expected notifications with counts %v, got %v", tt.counts, counts)
+			}
+		})
+	}
+}
+
+func TestCheckpointCooldown(t *testing.T) {
+	var committed, resumed time.Time
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return nil }).Checkpoint(),
+		retryflow.Exec(func(ctx context.Context) error {
+			resumed = time.Now()
+			return nil
+		}),
+	),
+		retryflow.WithCheckpointCooldown(50*time.Millisecond),
+		retryflow.WithOnCheckpoint(func(step int, output any) { committed = time.Now() }),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if gap := resumed.Sub(committed); gap < 50*time.Millisecond {
+		t.Errorf("expected at least 50ms between the checkpoint and the next step, got %v", gap)
+	}
+
+	// The cooldown respects the context
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	ran := false
+	err = retryflow.Retry(ctx, retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return nil }).Checkpoint(),
+		retryflow.Exec(func(ctx context.Context) error {
+			ran = true
+			return nil
+		}),
+	), retryflow.WithCheckpointCooldown(time.Hour))
+	if !errors.Is(err, context.DeadlineExceeded) || ran {
+		t.Errorf("expected the cooldown to end with the context before step 2, got %v (ran: %v)", err, ran)
+	}
+}
+
+func TestMaxSameOutput(t *testing.T) {
+	polls := 0
+	var reason retryflow.GiveUpReason
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (string, error) {
+			polls++
+			return "pending", nil
+		}),
+		retryflow.Chain(func(ctx context.Context, status string) (string, error) {
+			if status != "done" {
+				return "", errors.New("not done")
+			}
+			return status, nil
+		}),
+	),
+		retryflow.WithMaxSameOutput(1, 3),
+		retryflow.WithMaxRetries(10),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithOnGiveUp(func(attempt int, err error, r retryflow.GiveUpReason) { reason = r }),
+	)
+	var stuck *retryflow.StuckError
+	if !errors.As(err, &stuck) {
+		t.Fatalf("expected *StuckError, got %v", err)
+	}
+	if stuck.Step != 1 || stuck.Repeats != 3 || stuck.Output != "pending" {
+		t.Errorf("unexpected StuckError: %+v", *stuck)
+	}
+	if polls != 4 {
+		t.Errorf("expected to give up on the 4th identical output, got %d polls", polls)
+	}
+	if reason != retryflow.GiveUpStuck {
+		t.Errorf("expected GiveUpStuck, got %v", reason)
+	}
+}
+
+func TestExecPassesInputThrough(t *testing.T) {
+	var got int
+	audits := 0
+	fails := 1
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Chain(func(ctx context.Context, _ any) (int, error) { return 7, nil }),
+		retryflow.Exec(func(ctx context.Context) error {
+			audits++
+			return nil
+		}).Checkpoint(),
+		retryflow.Chain(func(ctx context.Context, in int) (int, error) {
+			if fails > 0 {
+				fails--
+				return 0, errors.New("fail")
+			}
+			return in * 2, nil
+		}).Do(&got),
+	),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// The retry resumes after the Exec checkpoint with the value it passed on
+	if got != 14 || audits != 1 {
+		t.Errorf("expected 14 after a single audit, got %d after %d", got, audits)
+	}
+}
+
+func TestStepNameInErrorsAndHooks(t *testing.T) {
+	errTimeout := errors.New("timeout")
+	fails := 1
+	var retried []error
+	var succeeded []string
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return nil }).Name("login"),
+		retryflow.Exec(func(ctx context.Context) error {
+			if fails > 0 {
+				fails--
+				return errTimeout
+			}
+			return nil
+		}).Name("fetch-token"),
+		retryflow.Exec(func(ctx context.Context) error { return permanentErr{} }),
+	),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+		retryflow.WithOnRetry(func(attempt int, err error) { retried = append(retried, err) }),
+		retryflow.WithOnNamedStepSuccess(func(step int, name string, output any) {
+			succeeded = append(succeeded, fmt.Sprintf("%d:%s", step, name))
+		}),
+	)
+
+	if len(retried) != 1 || retried[0].Error() != "attempt 1, step 'fetch-token': timeout" {
+		t.Fatalf("expected one retry naming fetch-token, got %v", retried)
+	}
+	var ae *retryflow.AttemptError
+	if !errors.As(retried[0], &ae) || ae.Step != 2 || ae.StepName != "fetch-token" {
+		t.Errorf("expected step 2 named fetch-token, got %+v", ae)
+	}
+	// Unnamed steps fall back to their index
+	if err == nil || err.Error() != "attempt 2, step 3: permanent" {
+		t.Errorf("expected the unnamed step by index, got %v", err)
+	}
+	if want := []string{"1:login", "1:login", "2:fetch-token"}; !slices.Equal(succeeded, want) {
+		t.Errorf("expected successes %v, got %v", want, succeeded)
+	}
+}
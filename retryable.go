@@ -0,0 +1,109 @@
+package retryflow
+
+import (
+	"errors"
+	"reflect"
+)
+
+// isRetryable reports whether the failure err of step, which may be nil,
+// should be retried. The WithRetryable predicate receives the root cause of
+// err, and WithRetryableClasses checks its class. Without either, errors in
+// the chain implementing Permanent() bool or Temporary() bool, like
+// net.Error, decide for themselves, and all other errors are retried.
+func (o *options) isRetryable(err error, step *Step) bool {
+	if o.retryable != nil {
+		return o.retryable(fullUnwrap(err))
+	}
+	if o.retryableClasses != nil {
+		class, _ := o.classify(fullUnwrap(err), step)
+		return o.retryableClasses[class]
+	}
+	var permanent interface{ Permanent() bool }
+	if errors.As(err, &permanent) {
+		return !permanent.Permanent()
+	}
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) {
+		return temporary.Temporary()
+	}
+	return true
+}
+
+// RetryableAll returns a predicate for WithRetryable that reports true only
+// if every pred reports true.
+func RetryableAll(preds ...func(err error) bool) func(err error) bool {
+	return func(err error) bool {
+		for _, p := range preds {
+			if !p(err) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// RetryableAny returns a predicate for WithRetryable that reports true if
+// at least one pred reports true.
+func RetryableAny(preds ...func(err error) bool) func(err error) bool {
+	return func(err error) bool {
+		for _, p := range preds {
+			if p(err) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RetryableIs returns a predicate for WithRetryable that reports true if
+// errors.Is matches err against one of targets. Like any WithRetryable
+// predicate it receives the root cause of the failure, so targets should be
+// root causes too; used on its own it also accepts wrapped errors such as an
+// *AttemptError.
+func RetryableIs(targets ...error) func(err error) bool {
+	return func(err error) bool {
+		for _, target := range targets {
+			if errors.Is(err, target) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RetryableAs returns a predicate for WithRetryable that reports true if
+// errors.As finds an error of the type one of templates points to, e.g.
+// new(*net.OpError). Each template must be a non-nil pointer as accepted by
+// errors.As; the predicate never writes through it.
+func RetryableAs(templates ...any) func(err error) bool {
+	types := make([]reflect.Type, len(templates))
+	for i, tmpl := range templates {
+		val := reflect.ValueOf(tmpl)
+		if !val.IsValid() || val.Kind() != reflect.Pointer || val.IsNil() {
+			panic("retryflow: RetryableAs template must be a non-nil pointer")
+		}
+		types[i] = val.Type().Elem()
+	}
+	return func(err error) bool {
+		for _, typ := range types {
+			if errors.As(err, reflect.New(typ).Interface()) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// NonRetryableIs is the inverse of RetryableIs: errors matching one of
+// targets are not retried, all others are.
+func NonRetryableIs(targets ...error) func(err error) bool {
+	is := RetryableIs(targets...)
+	return func(err error) bool { return !is(err) }
+}
+
+// NonRetryableAs is the inverse of RetryableAs: errors of the types templates
+// point to are not retried, all others are.
+func NonRetryableAs(templates ...any) func(err error) bool {
+	as := RetryableAs(templates...)
+	return func(err error) bool { return !as(err) }
+}
@@ -0,0 +1,224 @@
+package retryflow_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Vealcoo/retryflow"
+)
+
+func TestRetryableCombinators(t *testing.T) {
+	isTransient := func(err error) bool { return strings.Contains(err.Error(), "transient") }
+	isRemote := func(err error) bool { return strings.Contains(err.Error(), "remote") }
+
+	all := retryflow.RetryableAll(isTransient, isRemote)
+	anyOf := retryflow.RetryableAny(isTransient, isRemote)
+
+	tests := []struct {
+		err     error
+		wantAll bool
+		wantAny bool
+	}{
+		{errors.New("transient remote failure"), true, true},
+		{errors.New("transient local failure"), false, true},
+		{errors.New("remote permanent failure"), false, true},
+		{errors.New("local permanent failure"), false, false},
+	}
+	for _, tt := range tests {
+		if got := all(tt.err); got != tt.wantAll {
+			t.Errorf("RetryableAll(%q) = %v, want %v", tt.err, got, tt.wantAll)
+		}
+		if got := anyOf(tt.err); got != tt.wantAny {
+			t.Errorf("RetryableAny(%q) = %v, want %v", tt.err, got, tt.wantAny)
+		}
+	}
+}
+
+type temporaryError struct{ temporary bool }
+
+func (e temporaryError) Error() string   { return "temporary error" }
+func (e temporaryError) Temporary() bool { return e.temporary }
+
+type permanentError struct{}
+
+func (permanentError) Error() string   { return "permanent error" }
+func (permanentError) Permanent() bool { return true }
+
+func TestMarkerInterfaces(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		opts         []retryflow.Option
+		wantAttempts int
+	}{
+		{"TemporaryFalse", temporaryError{false}, nil, 1},
+		{"TemporaryTrue", temporaryError{true}, nil, 3},
+		{"PermanentWrapped", fmt.Errorf("call failed: %w", permanentError{}), nil, 1},
+		{"ExplicitRetryableWins", temporaryError{false}, []retryflow.Option{
+			retryflow.WithRetryable(func(err error) bool { return true }),
+		}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			opts := append([]retryflow.Option{
+				retryflow.WithMaxRetries(3),
+				retryflow.WithInitialBackoff(time.Millisecond),
+				retryflow.WithJitter(0),
+			}, tt.opts...)
+			err := retryflow.Retry(context.Background(), retryflow.Seq(
+				retryflow.Exec(func(ctx context.Context) error {
+					attempts++
+					return tt.err
+				}),
+			), opts...)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("expected %d attempts, got %d", tt.wantAttempts, attempts)
+			}
+		})
+	}
+}
+
+func TestStepRetryableOverride(t *testing.T) {
+	errTimeout := errors.New("timeout")
+	isTimeout := func(err error) bool { return errors.Is(err, errTimeout) }
+	notTimeout := func(err error) bool { return !isTimeout(err) }
+
+	tests := []struct {
+		name         string
+		step         func(run func(context.Context) error) *retryflow.Step
+		wantAttempts int
+	}{
+		{"Global", func(run func(context.Context) error) *retryflow.Step {
+			return retryflow.Exec(run)
+		}, 3},
+		{"PermanentTimeouts", func(run func(context.Context) error) *retryflow.Step {
+			return retryflow.Exec(run).Retryable(notTimeout)
+		}, 1},
+		{"TransientTimeouts", func(run func(context.Context) error) *retryflow.Step {
+			return retryflow.Exec(run).Retryable(isTimeout)
+		}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := retryflow.Retry(context.Background(), retryflow.Seq(
+				retryflow.Exec(func(ctx context.Context) error { return nil }),
+				tt.step(func(ctx context.Context) error {
+					attempts++
+					return errTimeout
+				}),
+			),
+				retryflow.WithRetryable(isTimeout),
+				retryflow.WithMaxRetries(3),
+				retryflow.WithInitialBackoff(time.Millisecond),
+				retryflow.WithJitter(0),
+			)
+			if !errors.Is(err, errTimeout) {
+				t.Fatalf("expected errTimeout, got %v", err)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("expected %d attempts, got %d", tt.wantAttempts, attempts)
+			}
+		})
+	}
+}
+
+func TestRetryableClasses(t *testing.T) {
+	errTimeout := errors.New("timeout")
+	errThrottled := errors.New("throttled")
+	errForbidden := errors.New("forbidden")
+	classifier := func(err error) retryflow.ErrorClass {
+		switch err {
+		case errTimeout:
+			return retryflow.ClassTransient
+		case errThrottled:
+			return retryflow.ClassRateLimit
+		case errForbidden:
+			return retryflow.ClassAuth
+		}
+		return retryflow.ClassUnknown
+	}
+	errs := []error{errTimeout, errThrottled, errTimeout, errForbidden, errTimeout}
+	attempts := 0
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			attempts++
+			return errs[attempts-1]
+		}),
+	),
+		retryflow.WithRetryableClasses(retryflow.ClassTransient, retryflow.ClassRateLimit),
+		retryflow.WithErrorClassifier(classifier),
+		retryflow.WithMaxRetries(10),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if !errors.Is(err, errForbidden) {
+		t.Fatalf("expected errForbidden, got %v", err)
+	}
+	if attempts != 4 {
+		t.Errorf("expected to abort on the auth failure at attempt 4, got %d attempts", attempts)
+	}
+}
+
+type quotaError struct{ resource string }
+
+func (e *quotaError) Error() string { return "quota exceeded for " + e.resource }
+
+func TestRetryableIsAs(t *testing.T) {
+	errBusy := errors.New("busy")
+	errGone := errors.New("gone")
+	quota := &quotaError{"cpu"}
+
+	tests := []struct {
+		name string
+		pred func(error) bool
+		err  error
+		want bool
+	}{
+		{"IsMatch", retryflow.RetryableIs(errGone, errBusy), errBusy, true},
+		{"IsWrapped", retryflow.RetryableIs(errBusy), &retryflow.AttemptError{Attempt: 1, Step: 1, Err: fmt.Errorf("call: %w", errBusy)}, true},
+		{"IsNoMatch", retryflow.RetryableIs(errBusy), errGone, false},
+		{"AsMatch", retryflow.RetryableAs(new(*quotaError)), fmt.Errorf("call: %w", quota), true},
+		{"AsNoMatch", retryflow.RetryableAs(new(*quotaError)), errBusy, false},
+		{"AsInterface", retryflow.RetryableAs(new(interface{ Temporary() bool })), temporaryError{true}, true},
+		{"NonIsMatch", retryflow.NonRetryableIs(errGone), errGone, false},
+		{"NonIsNoMatch", retryflow.NonRetryableIs(errGone), quota, true},
+		{"NonAsMatch", retryflow.NonRetryableAs(new(*quotaError)), quota, false},
+		{"NonAsNoMatch", retryflow.NonRetryableAs(new(*quotaError)), errBusy, true},
+		{"Mixed", retryflow.RetryableAny(retryflow.RetryableIs(errBusy), retryflow.RetryableAs(new(*quotaError))), quota, true},
+	}
+	for _, tt := range tests {
+		if got := tt.pred(tt.err); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Sentinels are retried, quota errors abort
+	errs := []error{errBusy, errBusy, quota}
+	attempts := 0
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			attempts++
+			return errs[attempts-1]
+		}),
+	),
+		retryflow.WithRetryable(retryflow.NonRetryableAs(new(*quotaError))),
+		retryflow.WithMaxRetries(5),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	var qe *quotaError
+	if !errors.As(err, &qe) || attempts != 3 {
+		t.Errorf("expected to abort on the quota error at attempt 3, got %v after %d attempts", err, attempts)
+	}
+}
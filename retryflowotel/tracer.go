@@ -0,0 +1,89 @@
+// Package retryflowotel traces retryflow flows with OpenTelemetry. It is a
+// separate module so that only its users depend on OpenTelemetry.
+package retryflowotel
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Vealcoo/retryflow"
+)
+
+// Attribute keys set on the spans.
+const (
+	FlowKey    = attribute.Key("retryflow.flow")
+	AttemptKey = attribute.Key("retryflow.attempt")
+	StepKey    = attribute.Key("retryflow.step")
+	ClassKey   = attribute.Key("retryflow.error_class")
+	BackoffKey = attribute.Key("retryflow.backoff_ms")
+)
+
+var _ retryflow.Observer = observer{}
+
+// WithTracer traces the flow with tracer: a span per Retry call, a child
+// span per attempt and a grandchild span per step. Attempt spans record
+// the error class and the backoff that followed a failure; failed spans
+// record their error. Steps receive their span in their context.
+func WithTracer(tracer trace.Tracer) retryflow.Option {
+	return retryflow.WithObserver(observer{tracer})
+}
+
+type observer struct {
+	tracer trace.Tracer
+}
+
+func (o observer) StartFlow(ctx context.Context, name string) context.Context {
+	spanName := "retryflow.Retry"
+	if name != "" {
+		spanName += " " + name
+	}
+	ctx, _ = o.tracer.Start(ctx, spanName, trace.WithAttributes(FlowKey.String(name)))
+	return ctx
+}
+
+func (o observer) EndFlow(ctx context.Context, err error) {
+	end(ctx, err)
+}
+
+func (o observer) StartAttempt(ctx context.Context, attempt int) context.Context {
+	ctx, _ = o.tracer.Start(ctx, "retryflow.attempt", trace.WithAttributes(AttemptKey.Int(attempt)))
+	return ctx
+}
+
+func (o observer) EndAttempt(ctx context.Context, err error, class retryflow.ErrorClass, backoff time.Duration) {
+	span := trace.SpanFromContext(ctx)
+	if err != nil {
+		span.SetAttributes(ClassKey.String(string(class)))
+	}
+	if backoff > 0 {
+		span.SetAttributes(BackoffKey.Int64(backoff.Milliseconds()))
+	}
+	end(ctx, err)
+}
+
+func (o observer) StartStep(ctx context.Context, step int, name string) context.Context {
+	spanName := "retryflow.step"
+	if name != "" {
+		spanName += " " + name
+	}
+	ctx, _ = o.tracer.Start(ctx, spanName, trace.WithAttributes(StepKey.Int(step)))
+	return ctx
+}
+
+func (o observer) EndStep(ctx context.Context, err error) {
+	end(ctx, err)
+}
+
+// end records err, if any, on the span in ctx and ends it.
+func end(ctx context.Context, err error) {
+	span := trace.SpanFromContext(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,141 @@
+package retryflowotel_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Vealcoo/retryflow"
+	"github.com/Vealcoo/retryflow/retryflowotel"
+)
+
+func TestWithTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	errFail := errors.New("fail")
+	failed := false
+	var stepSpan trace.SpanContext
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return nil }).Name("reserve"),
+		retryflow.Exec(func(ctx context.Context) error {
+			stepSpan = trace.SpanContextFromContext(ctx)
+			if !failed {
+				failed = true
+				return errFail
+			}
+			return nil
+		}),
+	),
+		retryflowotel.WithTracer(provider.Tracer("test")),
+		retryflow.WithFlowName("checkout"),
+		retryflow.WithErrorClassifier(func(error) retryflow.ErrorClass { return retryflow.ClassTransient }),
+		retryflow.WithInitialBackoff(5*time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	spans := recorder.Ended()
+	byID := make(map[trace.SpanID]sdktrace.ReadOnlySpan)
+	var names []string
+	for _, s := range spans {
+		byID[s.SpanContext().SpanID()] = s
+		names = append(names, s.Name())
+	}
+	// Spans end innermost first
+	want := []string{
+		"retryflow.step reserve", "retryflow.step", "retryflow.attempt",
+		"retryflow.step reserve", "retryflow.step", "retryflow.attempt",
+		"retryflow.Retry checkout",
+	}
+	if len(names) != len(want) {
+		t.Fatalf("expected spans %v, got %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("expected spans %v, got %v", want, names)
+		}
+	}
+
+	root := spans[6]
+	for i, s := range spans[:6] {
+		parent := byID[s.Parent().SpanID()]
+		if s.Name() == "retryflow.attempt" {
+			if parent != root {
+				t.Errorf("span %d: expected the flow span as parent", i)
+			}
+		} else if parent == nil || parent.Name() != "retryflow.attempt" {
+			t.Errorf("span %d: expected an attempt span as parent", i)
+		}
+	}
+	if !stepSpan.Equal(spans[4].SpanContext()) {
+		t.Error("expected the step to run with its span in its context")
+	}
+
+	failedStep, failedAttempt := spans[1], spans[2]
+	if failedStep.Status().Code != codes.Error || len(failedStep.Events()) != 1 || failedStep.Events()[0].Name != "exception" {
+		t.Errorf("expected the failed step span to record the error, got %v and %v", failedStep.Status(), failedStep.Events())
+	}
+	wantAttrs := []attribute.KeyValue{
+		retryflowotel.AttemptKey.Int(1),
+		retryflowotel.ClassKey.String("transient"),
+		retryflowotel.BackoffKey.Int64(5),
+	}
+	for _, kv := range wantAttrs {
+		if !hasAttr(failedAttempt, kv) {
+			t.Errorf("expected attribute %v on the failed attempt, got %v", kv, failedAttempt.Attributes())
+		}
+	}
+	if !hasAttr(spans[0], retryflowotel.StepKey.Int(1)) || !hasAttr(spans[4], retryflowotel.StepKey.Int(2)) {
+		t.Error("expected step spans to carry their index")
+	}
+	if root.Status().Code == codes.Error || spans[5].Status().Code == codes.Error {
+		t.Error("expected the successful attempt and flow spans not to record errors")
+	}
+}
+
+func TestWithTracerRecordsGiveUp(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	errFail := errors.New("fail")
+
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return errFail }),
+	),
+		retryflowotel.WithTracer(provider.Tracer("test")),
+		retryflow.WithMaxRetries(2),
+		retryflow.WithInitialBackoff(time.Millisecond),
+		retryflow.WithJitter(0),
+	)
+	if !errors.Is(err, errFail) {
+		t.Fatalf("expected errFail, got %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 5 {
+		t.Fatalf("expected 2 attempts of 1 step and the flow span, got %d spans", len(spans))
+	}
+	for _, s := range spans {
+		if s.Status().Code != codes.Error {
+			t.Errorf("expected span %q to record the error", s.Name())
+		}
+	}
+}
+
+func hasAttr(s sdktrace.ReadOnlySpan, kv attribute.KeyValue) bool {
+	for _, a := range s.Attributes() {
+		if a == kv {
+			return true
+		}
+	}
+	return false
+}
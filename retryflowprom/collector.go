@@ -0,0 +1,124 @@
+// Package retryflowprom exports retryflow metrics to Prometheus. It is a
+// separate module so that only its users depend on the Prometheus client.
+package retryflowprom
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Vealcoo/retryflow"
+)
+
+var _ retryflow.Observer = (*Collector)(nil)
+
+// Collector counts attempts, retries, give-ups and errors by class, and
+// records backoff and flow durations, labeled with the WithFlowName name.
+// Create it with NewCollector and attach it to flows with WithMetrics; one
+// Collector can serve any number of flows.
+type Collector struct {
+	attempts *prometheus.CounterVec
+	retries  *prometheus.CounterVec
+	giveUps  *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	backoff  *prometheus.HistogramVec
+	duration *prometheus.HistogramVec
+}
+
+// NewCollector creates a Collector and registers its metrics with reg.
+func NewCollector(reg prometheus.Registerer) (*Collector, error) {
+	c := &Collector{
+		attempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "retryflow_attempts_total",
+			Help: "Attempts started.",
+		}, []string{"flow"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "retryflow_retries_total",
+			Help: "Failed attempts followed by a backoff sleep.",
+		}, []string{"flow"}),
+		giveUps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "retryflow_give_ups_total",
+			Help: "Flows that returned an error.",
+		}, []string{"flow"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "retryflow_errors_total",
+			Help: "Failed attempts by error class.",
+		}, []string{"flow", "class"}),
+		backoff: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "retryflow_backoff_seconds",
+			Help:    "Backoff sleeps between attempts.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 8),
+		}, []string{"flow"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "retryflow_flow_duration_seconds",
+			Help:    "Total duration of flows, backoff sleeps included.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 8),
+		}, []string{"flow"}),
+	}
+	for _, m := range []prometheus.Collector{c.attempts, c.retries, c.giveUps, c.errors, c.backoff, c.duration} {
+		if err := reg.Register(m); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// WithMetrics records the metrics of the flow in c.
+func WithMetrics(c *Collector) retryflow.Option {
+	return retryflow.WithObserver(c)
+}
+
+// flowKey is the context key of the running flow.
+type flowKey struct{}
+
+type flow struct {
+	name  string
+	start time.Time
+}
+
+func flowFrom(ctx context.Context) flow {
+	f, _ := ctx.Value(flowKey{}).(flow)
+	return f
+}
+
+// StartFlow implements retryflow.Observer.
+func (c *Collector) StartFlow(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, flowKey{}, flow{name, time.Now()})
+}
+
+// EndFlow implements retryflow.Observer.
+func (c *Collector) EndFlow(ctx context.Context, err error) {
+	f := flowFrom(ctx)
+	c.duration.WithLabelValues(f.name).Observe(time.Since(f.start).Seconds())
+	if err != nil {
+		c.giveUps.WithLabelValues(f.name).Inc()
+	}
+}
+
+// StartAttempt implements retryflow.Observer.
+func (c *Collector) StartAttempt(ctx context.Context, attempt int) context.Context {
+	c.attempts.WithLabelValues(flowFrom(ctx).name).Inc()
+	return ctx
+}
+
+// EndAttempt implements retryflow.Observer.
+func (c *Collector) EndAttempt(ctx context.Context, err error, class retryflow.ErrorClass, backoff time.Duration) {
+	if err == nil {
+		return
+	}
+	name := flowFrom(ctx).name
+	c.errors.WithLabelValues(name, string(class)).Inc()
+	if backoff > 0 {
+		c.retries.WithLabelValues(name).Inc()
+		c.backoff.WithLabelValues(name).Observe(backoff.Seconds())
+	}
+}
+
+// StartStep implements retryflow.Observer.
+func (c *Collector) StartStep(ctx context.Context, step int, name string) context.Context {
+	return ctx
+}
+
+// EndStep implements retryflow.Observer.
+func (c *Collector) EndStep(ctx context.Context, err error) {}
@@ -0,0 +1,158 @@
+package retryflowprom_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/Vealcoo/retryflow"
+	"github.com/Vealcoo/retryflow/retryflowprom"
+)
+
+func TestCollector(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	collector, err := retryflowprom.NewCollector(reg)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	classify := func(err error) retryflow.ErrorClass {
+		if err.Error() == "timeout" {
+			return retryflow.ClassTimeout
+		}
+		return retryflow.ClassRateLimit
+	}
+	opts := func(name string) []retryflow.Option {
+		return []retryflow.Option{
+			retryflowprom.WithMetrics(collector),
+			retryflow.WithFlowName(name),
+			retryflow.WithErrorClassifier(classify),
+			retryflow.WithMaxRetries(3),
+			retryflow.WithInitialBackoff(time.Millisecond),
+			retryflow.WithJitter(0),
+		}
+	}
+
+	// Fails twice with timeouts, then succeeds
+	attempts := 0
+	err = retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error {
+			attempts++
+			if attempts <= 2 {
+				return errors.New("timeout")
+			}
+			return nil
+		}),
+	), opts("sync")...)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// Fails on every attempt with rate limits
+	err = retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return errors.New("throttled") }),
+	), opts("upload")...)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	counters := []struct {
+		name   string
+		labels map[string]string
+		want   float64
+	}{
+		{"retryflow_attempts_total", map[string]string{"flow": "sync"}, 3},
+		{"retryflow_retries_total", map[string]string{"flow": "sync"}, 2},
+		{"retryflow_give_ups_total", map[string]string{"flow": "sync"}, 0},
+		{"retryflow_errors_total", map[string]string{"flow": "sync", "class": "timeout"}, 2},
+		{"retryflow_attempts_total", map[string]string{"flow": "upload"}, 3},
+		{"retryflow_retries_total", map[string]string{"flow": "upload"}, 2},
+		{"retryflow_give_ups_total", map[string]string{"flow": "upload"}, 1},
+		{"retryflow_errors_total", map[string]string{"flow": "upload", "class": "ratelimit"}, 3},
+	}
+	for _, c := range counters {
+		if got := counterValue(t, reg, c.name, c.labels); got != c.want {
+			t.Errorf("%s%v = %v, want %v", c.name, c.labels, got, c.want)
+		}
+	}
+
+	if n := testutil.CollectAndCount(reg, "retryflow_backoff_seconds"); n != 2 {
+		t.Errorf("expected backoff histograms for both flows, got %d", n)
+	}
+	if n := testutil.CollectAndCount(reg, "retryflow_flow_duration_seconds"); n != 2 {
+		t.Errorf("expected duration histograms for both flows, got %d", n)
+	}
+	if got := histogramCount(t, reg, "retryflow_backoff_seconds", "upload"); got != 2 {
+		t.Errorf("expected 2 upload backoff samples, got %d", got)
+	}
+	if got := histogramCount(t, reg, "retryflow_flow_duration_seconds", "sync"); got != 1 {
+		t.Errorf("expected 1 sync duration sample, got %d", got)
+	}
+}
+
+func TestNewCollectorRegisterError(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if _, err := retryflowprom.NewCollector(reg); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := retryflowprom.NewCollector(reg); err == nil {
+		t.Error("expected registering twice to fail")
+	}
+}
+
+// counterValue returns the value of the counter name with the given labels,
+// or 0 if it was never incremented.
+func counterValue(t *testing.T, reg *prometheus.Registry, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			if matchLabels(m.GetLabel(), labels) {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+func histogramCount(t *testing.T, reg *prometheus.Registry, name, flow string) uint64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			if matchLabels(m.GetLabel(), map[string]string{"flow": flow}) {
+				return m.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	return 0
+}
+
+// matchLabels reports whether the labels of a metric are exactly labels.
+func matchLabels(pairs []*dto.LabelPair, labels map[string]string) bool {
+	if len(pairs) != len(labels) {
+		return false
+	}
+	for _, p := range pairs {
+		if v, ok := labels[p.GetName()]; !ok || v != p.GetValue() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package retryflow
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// simulateCap bounds the schedule of flows without any limit, and
+// simulateHardCap that of flows limited only by WithMaxElapsedTime, whose
+// sleeps may never add up to it, e.g. when they are all zero.
+const (
+	simulateCap     = 20
+	simulateHardCap = 10000
+)
+
+// SimulateSchedule returns the backoff sleeps a flow configured with opts
+// would make if every attempt failed instantly with an error created by
+// errors.New: one per retry up to WithMaxRetries and WithMaxElapsedTime, or
+// 20 retries when neither bounds the flow. Without a retry limit it stops
+// after 10000 retries in any case. The error is classified as the
+// flow would classify it, by WithErrorClassifier if set, so class-dependent
+// settings apply. Jitter is drawn from a fixed seed, so the result is
+// deterministic. The options are not validated.
+func SimulateSchedule(opts ...Option) []time.Duration {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.rand == nil {
+		o.rand = &lockedRand{r: rand.New(rand.NewSource(1))}
+	}
+
+	key, _ := o.classify(errors.New("simulated failure"), nil)
+
+	var sleeps []time.Duration
+	var elapsed time.Duration
+	prev := o.initialBackoff
+	for attempt := 1; ; attempt++ {
+		if o.maxRetries >= 0 && attempt >= o.maxRetries {
+			break
+		}
+		if o.maxElapsedTime > 0 && elapsed >= o.maxElapsedTime {
+			break
+		}
+		if o.maxRetries < 0 {
+			limit := simulateHardCap
+			if o.maxElapsedTime <= 0 {
+				limit = simulateCap
+			}
+			if len(sleeps) >= limit {
+				break
+			}
+		}
+		next, sleep := o.backoff(attempt, elapsed, prev, key, nil, attempt)
+		sleeps = append(sleeps, sleep)
+		elapsed += sleep
+		prev = next
+	}
+	return sleeps
+}
+
+// backoff returns the backoff computed after the failed attempt, given the
+// elapsed time and the previous backoff prev, and the sleep it turns into
+// after load scaling, jitter and the class floor, for a failure of class key
+// caused by step, which is nil if no step failed, and the streak-th in a row
+// of its class.
+func (o *options) backoff(attempt int, elapsed, prev time.Duration, key ErrorClass, step *Step, streak int) (next, sleep time.Duration) {
+	switch {
+	case o.backoffFunc != nil:
+		next = o.backoffFunc(attempt, elapsed, prev)
+	case o.decorrelatedCap > 0:
+		next = decorrelatedJitter(o.decorrelatedBase, o.decorrelatedCap, prev, o.int63n)
+	default:
+		next = o.backoffStrategy(attempt, prev)
+	}
+	next = min(next, o.maxBackoff)
+	sleep = max(o.jittered(o.loadScaled(next), key, step, streak), o.minBackoffByClass[key])
+	return next, sleep
+}
@@ -0,0 +1,19 @@
+package retryflow
+
+// Stats collects information about a single Retry run.
+// Pass a pointer with WithStats and it is filled in before Retry returns.
+type Stats struct {
+	// AttemptsToSuccess is the attempt number on which the flow succeeded,
+	// counted across checkpoints, or -1 if the flow failed.
+	AttemptsToSuccess int
+	// LastErrorByClass holds the most recent error seen for each error class.
+	LastErrorByClass map[ErrorClass]error
+	// Outputs holds the latest output of each successful step by 1-based
+	// index, limited to the steps selected with WithCaptureSteps.
+	Outputs map[int]any
+	// ExecutedSteps holds, for each attempt in order, the 1-based indices of
+	// the steps that actually ran, leaving out skipped steps, so the branch
+	// taken by When predicates can be seen. WithMaxEventHistory limits it
+	// to the latest attempts.
+	ExecutedSteps [][]int
+}
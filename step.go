@@ -2,28 +2,78 @@ package retryflow
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"time"
 )
 
 // Step defines a single step in the retry sequence.
 type Step struct {
-	run        func(ctx context.Context, input any) (any, error) // Execution function that takes context, input and returns output and error
-	outputPtr  any                                               // Pointer to store the output (*T)
-	checkpoint bool
-	onFail     func()
+	run         func(ctx context.Context, input any) (any, error) // Execution function that takes context, input and returns output and error
+	outputPtr   any                                               // Pointer to store the output (*T)
+	inType      reflect.Type                                      // Input type expected by Chain, used for coercion
+	store       func(output any)                                  // Sink receiving the output, alternative to outputPtr
+	checkpoint  bool
+	onFail      func()
+	when        func(input any) bool                        // Predicate deciding whether the step runs
+	optional    bool                                        // Failures skip the step instead of failing the attempt
+	minBudget   time.Duration                               // Minimum remaining budget required to run the step
+	label       string                                      // Target name for RetryFrom
+	retryFrom   string                                      // Label of the step the next attempt resumes from after a failure
+	budgetFrac  float64                                     // Fraction of the remaining budget used as the step deadline
+	timeout     time.Duration                               // Deadline of each run of the step
+	jitter      *time.Duration                              // Overrides the flow jitter for retries caused by the step
+	name        string                                      // Descriptive name, for exports and diagnostics
+	meta        map[string]string                           // Free-form metadata, for exports
+	compensate  func(ctx context.Context, output any) error // Undoes the step when the flow fails
+	retryable   func(err error) bool                        // Overrides WithRetryable for failures of the step
+	classify    func(err error) ErrorClass                  // Overrides WithErrorClassifier for failures of the step
+	maxAttempts int                                         // Maximum runs of the step before the flow fails
+	children    []*Step                                     // Steps run by Parallel
 }
 
-// Exec creates a step that executes a function without input/output.
+// SkipReason tells why a step was skipped.
+type SkipReason int
+
+const (
+	// ReasonPredicate means the step's When predicate returned false.
+	ReasonPredicate SkipReason = iota
+	// ReasonBudget means less than the step's MinBudget was left.
+	ReasonBudget
+	// ReasonOptionalFailure means an Optional step failed.
+	ReasonOptionalFailure
+)
+
+func (r SkipReason) String() string {
+	switch r {
+	case ReasonPredicate:
+		return "predicate"
+	case ReasonBudget:
+		return "budget"
+	case ReasonOptionalFailure:
+		return "optional failure"
+	default:
+		return fmt.Sprintf("SkipReason(%d)", int(r))
+	}
+}
+
+// Exec creates a step that executes a function without input/output. It
+// passes its input through as its output, so a value chains from the step
+// before it to the step after it, across a checkpoint too.
 func Exec(fn func(context.Context) error) *Step {
 	return &Step{
-		run: func(ctx context.Context, _ any) (any, error) {
-			return nil, fn(ctx)
+		run: func(ctx context.Context, input any) (any, error) {
+			if err := fn(ctx); err != nil {
+				return nil, err
+			}
+			return input, nil
 		},
 	}
 }
 
 func Chain[In any, Out any](fn func(context.Context, In) (Out, error)) *Step {
-	s := &Step{}
+	s := &Step{inType: reflect.TypeFor[In]()}
 	s.run = func(ctx context.Context, input any) (any, error) {
 		in, ok := input.(In)
 		if !ok && input != nil {
@@ -39,7 +89,20 @@ func (s *Step) Do(ptr any) *Step {
 	return s
 }
 
-// Checkpoint marks the step as a checkpoint.
+// Store sets a function that receives the step's output each time it succeeds.
+// It is an alternative to Do that needs no pointer and no reflection.
+func (s *Step) Store(fn func(output any)) *Step {
+	s.store = fn
+	return s
+}
+
+// Checkpoint marks the step as a checkpoint: once it succeeds, later
+// attempts resume after it, receiving its output, and the attempt counter,
+// backoff and (by default) per-error counts are reset.
+// When several checkpoints succeed in a row each one commits in turn, so the
+// flow resumes after the last of them. A checkpoint on the last step
+// protects no later step and is reported as ErrTrailingCheckpoint, unless
+// WithCheckpointStore or WithOnCheckpoint records it.
 func (s *Step) Checkpoint() *Step {
 	s.checkpoint = true
 	return s
@@ -51,10 +114,176 @@ func (s *Step) OnFail(fn func()) *Step {
 	return s
 }
 
+// When makes the step run only if pred returns true for its input.
+// A skipped step leaves its output untouched, passes its input on to the
+// next step and does not commit a checkpoint.
+func (s *Step) When(pred func(input any) bool) *Step {
+	s.when = pred
+	return s
+}
+
+// Optional makes a failure of the step skip it instead of failing the attempt.
+func (s *Step) Optional() *Step {
+	s.optional = true
+	return s
+}
+
+// MinBudget skips the step when less than d of the maxElapsedTime budget is left.
+func (s *Step) MinBudget(d time.Duration) *Step {
+	s.minBudget = d
+	return s
+}
+
+// BudgetFraction gives each run of the step a deadline of f times the budget
+// left at that point, the sooner of maxElapsedTime and the context deadline.
+// It has no effect when neither is set.
+func (s *Step) BudgetFraction(f float64) *Step {
+	s.budgetFrac = f
+	return s
+}
+
+// Timeout gives each run of the step its own deadline of d, independent of
+// WithMaxElapsedTime. A run that fails after the deadline passed reports an
+// error wrapping context.DeadlineExceeded, which is retried like any other.
+func (s *Step) Timeout(d time.Duration) *Step {
+	s.timeout = d
+	return s
+}
+
+// Jitter overrides the flow's WithJitter and WithJitterFraction for the
+// backoff after a failure of this step, so steps hitting contended resources
+// can spread their retries more widely. It applies to JitterAdditive only.
+func (s *Step) Jitter(d time.Duration) *Step {
+	s.jitter = &d
+	return s
+}
+
+// Compensate sets a handler undoing the step's side effects, for saga-style
+// flows. When Retry is about to return an error, the handlers of the steps
+// that succeeded since the last checkpoint (or, with WithCompensateAll, in
+// the whole flow) run in reverse order, each
+// receiving the output of its step. If the flow's context is done they get
+// a separate context, see WithCompensationContext. Their errors are joined
+// to the error Retry returns.
+func (s *Step) Compensate(fn func(ctx context.Context, output any) error) *Step {
+	s.compensate = fn
+	return s
+}
+
+// Retryable overrides WithRetryable and the Permanent/Temporary checks for
+// failures of this step. fn receives the fully unwrapped error.
+func (s *Step) Retryable(fn func(err error) bool) *Step {
+	s.retryable = fn
+	return s
+}
+
+// Classify overrides WithErrorClassifier for failures of this step, so the
+// same error can count against different limits depending on its source.
+func (s *Step) Classify(fn func(err error) ErrorClass) *Step {
+	s.classify = fn
+	return s
+}
+
+// MaxAttempts fails the flow with a *StepExhaustedError once the step has
+// run n times and failed, however often checkpoints reset the attempt
+// counter. Runs are counted across attempts and only start over for steps
+// that a later checkpoint commits.
+func (s *Step) MaxAttempts(n int) *Step {
+	s.maxAttempts = n
+	return s
+}
+
+// Name gives the step a descriptive name, included in Steps.Export and in
+// the *AttemptError of its failures.
+func (s *Step) Name(name string) *Step {
+	s.name = name
+	return s
+}
+
+// Meta attaches a key/value pair of metadata to the step, included in
+// Steps.Export.
+func (s *Step) Meta(key, value string) *Step {
+	if s.meta == nil {
+		s.meta = make(map[string]string)
+	}
+	s.meta[key] = value
+	return s
+}
+
+// Label names the step so that RetryFrom can refer to it.
+func (s *Step) Label(name string) *Step {
+	s.label = name
+	return s
+}
+
+// RetryFrom makes the next attempt after a failure of this step resume from
+// the step with the given label instead of the last checkpoint. The labeled
+// step receives the output its predecessor produced earlier in the flow. It
+// must be this step or an earlier one, and a label before the last
+// checkpoint falls back to the checkpoint, since those steps are committed.
+func (s *Step) RetryFrom(label string) *Step {
+	s.retryFrom = label
+	return s
+}
+
+// deliver hands a successful output to the step's Do pointer and Store sink,
+// converting it to the pointer's type first if coercion is set.
+func (s *Step) deliver(output any, coercion bool) error {
+	if s.outputPtr != nil {
+		ptrVal := reflect.ValueOf(s.outputPtr)
+		if ptrVal.Kind() != reflect.Ptr || ptrVal.IsNil() {
+			return errors.New("outputPtr must be a non-nil pointer")
+		}
+		stored := output
+		if coercion {
+			if v, ok := coerce(output, ptrVal.Elem().Type()); ok {
+				stored = v
+			}
+		}
+		if err := storeOutput(ptrVal.Elem(), stored); err != nil {
+			return err
+		}
+	}
+	if s.store != nil {
+		s.store(output)
+	}
+	return nil
+}
+
 // Steps is a sequence of steps.
 type Steps []*Step
 
 // Seq creates a sequence of steps.
+// The steps are copied, so later changes to the argument slice do not affect the sequence.
 func Seq(steps ...*Step) Steps {
-	return steps
+	return append(Steps(nil), steps...)
+}
+
+// labels maps step labels to their 0-based index and checks that labels
+// are unique and that every RetryFrom refers to the label of the step itself
+// or of an earlier one.
+func (s Steps) labels() (map[string]int, error) {
+	labels := make(map[string]int)
+	for i, step := range s {
+		if step.label == "" {
+			continue
+		}
+		if j, ok := labels[step.label]; ok {
+			return nil, fmt.Errorf("step %d: duplicate label %q, already on step %d", i+1, step.label, j+1)
+		}
+		labels[step.label] = i
+	}
+	for i, step := range s {
+		if step.retryFrom == "" {
+			continue
+		}
+		j, ok := labels[step.retryFrom]
+		if !ok {
+			return nil, fmt.Errorf("step %d: RetryFrom unknown label %q", i+1, step.retryFrom)
+		}
+		if j > i {
+			return nil, fmt.Errorf("step %d: RetryFrom label %q of the later step %d", i+1, step.retryFrom, j+1)
+		}
+	}
+	return labels, nil
 }
@@ -0,0 +1,50 @@
+package retryflow
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// TypedResult captures a value flowing through a chain without reflection.
+// Create one with Result and place its Step after the step whose output
+// it should capture:
+//
+//	total := retryflow.Result[int]()
+//	steps := retryflow.Seq(
+//		retryflow.Chain(fetch),
+//		retryflow.Chain(sum),
+//		total.Step(),
+//	)
+//	err := retryflow.Retry(ctx, steps)
+//	n, ok := total.Get()
+type TypedResult[T any] struct {
+	value T
+	set   bool
+}
+
+// Result returns an empty TypedResult for values of type T.
+func Result[T any]() *TypedResult[T] {
+	return &TypedResult[T]{}
+}
+
+// Step returns a step that stores its input in r and passes it on
+// unchanged to the next step. An input that is not a T fails the step.
+func (r *TypedResult[T]) Step() *Step {
+	return &Step{
+		inType: reflect.TypeFor[T](),
+		run: func(ctx context.Context, input any) (any, error) {
+			v, ok := input.(T)
+			if !ok && input != nil {
+				return nil, fmt.Errorf("expected %T but got %T", *new(T), input)
+			}
+			r.value, r.set = v, true
+			return input, nil
+		},
+	}
+}
+
+// Get returns the last captured value and whether the step has run.
+func (r *TypedResult[T]) Get() (T, bool) {
+	return r.value, r.set
+}
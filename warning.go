@@ -0,0 +1,40 @@
+package retryflow
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Configuration warnings passed to the WithOnWarning hook, or returned as
+// errors under WithStrictValidation. Match them with errors.Is.
+var (
+	// ErrJitterExceedsBackoff reports a jitter larger than the initial backoff,
+	// which makes early sleeps erratic and often clamps them to the floor.
+	ErrJitterExceedsBackoff = errors.New("jitter exceeds initialBackoff")
+	// ErrTrailingCheckpoint reports a checkpoint on the last step, which
+	// protects no later step. It is not reported when WithCheckpointStore or
+	// WithOnCheckpoint is set, since the checkpoint then records that the
+	// whole flow completed.
+	ErrTrailingCheckpoint = errors.New("checkpoint on the last step protects no later step")
+)
+
+// warnings returns the suspicious but valid settings of o for steps, as
+// *ConfigError so that strict mode can return them as they are.
+func (o *options) warnings(steps Steps) []error {
+	var warns []error
+	persisted := o.checkpointStore != nil || o.onCheckpoint != nil
+	if n := len(steps); n > 0 && steps[n-1].checkpoint && !persisted {
+		warns = append(warns, &ConfigError{Field: "steps", Err: fmt.Errorf("%w: step %d", ErrTrailingCheckpoint, n)})
+	}
+	if o.jitterMode == JitterAdditive && o.jitter > o.initialBackoff {
+		warns = append(warns, &ConfigError{Field: "jitter", Err: fmt.Errorf("%w: jitter %s > initialBackoff %s", ErrJitterExceedsBackoff, o.jitter, o.initialBackoff)})
+	}
+	return warns
+}
+
+// warn reports w to the onWarning hook.
+func (o *options) warn(w error) {
+	if o.onWarning != nil {
+		o.onWarning(w)
+	}
+}
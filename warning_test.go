@@ -0,0 +1,97 @@
+package retryflow_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Vealcoo/retryflow"
+)
+
+func TestJitterExceedsBackoffWarning(t *testing.T) {
+	steps := retryflow.Seq(retryflow.Exec(func(ctx context.Context) error { return nil }))
+	opts := []retryflow.Option{
+		retryflow.WithInitialBackoff(100 * time.Millisecond),
+		retryflow.WithJitter(500 * time.Millisecond),
+	}
+
+	var warnings []error
+	err := retryflow.Retry(context.Background(), steps, append(opts,
+		retryflow.WithOnWarning(func(err error) { warnings = append(warnings, err) }),
+	)...)
+	if err != nil {
+		t.Fatalf("expected warning only, got error %v", err)
+	}
+	if len(warnings) != 1 || !errors.Is(warnings[0], retryflow.ErrJitterExceedsBackoff) {
+		t.Errorf("expected ErrJitterExceedsBackoff warning, got %v", warnings)
+	}
+
+	err = retryflow.Retry(context.Background(), steps, append(opts, retryflow.WithStrictValidation(true))...)
+	if !errors.Is(err, retryflow.ErrJitterExceedsBackoff) {
+		t.Errorf("expected ErrJitterExceedsBackoff error in strict mode, got %v", err)
+	}
+}
+
+func TestNoWarningForSaneJitter(t *testing.T) {
+	var warnings []error
+	err := retryflow.Retry(context.Background(), retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return nil }),
+	),
+		retryflow.WithInitialBackoff(100*time.Millisecond),
+		retryflow.WithJitter(50*time.Millisecond),
+		retryflow.WithOnWarning(func(err error) { warnings = append(warnings, err) }),
+	)
+	if err != nil || len(warnings) != 0 {
+		t.Errorf("expected no warnings, got err=%v warnings=%v", err, warnings)
+	}
+}
+
+func TestTrailingCheckpointWarning(t *testing.T) {
+	steps := retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return nil }),
+		retryflow.Exec(func(ctx context.Context) error { return nil }).Checkpoint(),
+	)
+
+	var warnings []error
+	err := retryflow.Retry(context.Background(), steps,
+		retryflow.WithOnWarning(func(err error) { warnings = append(warnings, err) }),
+	)
+	if err != nil {
+		t.Fatalf("expected the flow to run, got %v", err)
+	}
+	if len(warnings) != 1 || !errors.Is(warnings[0], retryflow.ErrTrailingCheckpoint) {
+		t.Errorf("expected ErrTrailingCheckpoint warning, got %v", warnings)
+	}
+
+	err = retryflow.Retry(context.Background(), steps, retryflow.WithStrictValidation(true))
+	if !errors.Is(err, retryflow.ErrTrailingCheckpoint) {
+		t.Errorf("expected ErrTrailingCheckpoint error in strict mode, got %v", err)
+	}
+}
+
+func TestTrailingCheckpointPersisted(t *testing.T) {
+	steps := retryflow.Seq(
+		retryflow.Exec(func(ctx context.Context) error { return nil }),
+		retryflow.Exec(func(ctx context.Context) error { return nil }).Checkpoint(),
+	)
+	tests := []struct {
+		name string
+		opt  retryflow.Option
+	}{
+		{"Store", retryflow.WithCheckpointStore(&retryflow.MemoryCheckpointStore{}, "flow")},
+		{"Hook", retryflow.WithOnCheckpoint(func(int, any) {})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var warnings []error
+			err := retryflow.Retry(context.Background(), steps, tt.opt,
+				retryflow.WithStrictValidation(true),
+				retryflow.WithOnWarning(func(err error) { warnings = append(warnings, err) }),
+			)
+			if err != nil || len(warnings) != 0 {
+				t.Errorf("expected no warnings, got err=%v warnings=%v", err, warnings)
+			}
+		})
+	}
+}